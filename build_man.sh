@@ -1,8 +1,10 @@
 #!/bin/bash
 
+VERSION=$(sed -n 's/^const Version = "\(.*\)"$/\1/p' pkg/archlog/version.go)
+
 txt2man \
     -t arch-log \
     -v arch-log \
-    -r arch-log-$(<VERSION) \
+    -r arch-log-$VERSION \
     -s 1 \
     MANUAL.txt > arch-log.1
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// changelog is the log of a package grouped by release, as used by the markdown and html formats.
+type changelog struct {
+	Title    string
+	Releases []changelogRelease
+}
+
+type changelogRelease struct {
+	// Title is the version, or "Unreleased"
+	Title string
+	// Info consists of repo and date, if known
+	Info    string
+	Changes []changelogEntry
+}
+
+type changelogEntry struct {
+	URL     string
+	ShortId string
+	Summary string
+	Author  string
+	Date    string
+	Message string
+}
+
+func shortId(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func toChangelog(res logResult) changelog {
+	title := res.PkgBase + " (" + res.provider.Name()
+	if res.Repo != "" {
+		title += "/" + res.Repo
+	}
+	title += ")"
+
+	cl := changelog{Title: title}
+	for _, r := range sortedReleases(res.Changes) {
+		release := changelogRelease{Title: r.Tag}
+		if r.Tag == "" {
+			release.Title = "Unreleased"
+		}
+
+		var info []string
+		if r.RepoInfo != "" {
+			info = append(info, r.RepoInfo)
+		}
+		if !r.Time.IsZero() {
+			info = append(info, r.Time.Local().Format(time.DateOnly))
+		}
+		release.Info = strings.Join(info, ", ")
+
+		for _, c := range r.Changes {
+			entry := changelogEntry{
+				URL:     res.provider.CommitURL(res.PkgBase, c.Id),
+				ShortId: shortId(c.Id),
+				Summary: c.Summary,
+				Author:  c.Author,
+				Message: strings.TrimSpace(c.Message),
+			}
+			if !c.CommitTime.IsZero() {
+				entry.Date = c.CommitTime.Local().Format(time.DateOnly)
+			}
+			release.Changes = append(release.Changes, entry)
+		}
+
+		cl.Releases = append(cl.Releases, release)
+	}
+
+	return cl
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`)
+
+func formatMarkdown(w io.Writer, res logResult) error {
+	cl := toChangelog(res)
+	md := markdownEscaper.Replace
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "# %s\n", md(cl.Title))
+
+	for _, r := range cl.Releases {
+		fmt.Fprintf(&sb, "\n## %s", md(r.Title))
+		if r.Info != "" {
+			fmt.Fprintf(&sb, " (%s)", md(r.Info))
+		}
+		sb.WriteString("\n\n")
+
+		for _, e := range r.Changes {
+			fmt.Fprintf(&sb, "- [`%s`](%s) %s", e.ShortId, e.URL, md(e.Summary))
+			if e.Author != "" {
+				fmt.Fprintf(&sb, " — *%s*", md(e.Author))
+			}
+			if e.Date != "" {
+				fmt.Fprintf(&sb, ", %s", e.Date)
+			}
+			sb.WriteByte('\n')
+
+			if e.Message != "" {
+				// continuation of the list item
+				sb.WriteString("\n")
+				for _, line := range strings.Split(e.Message, "\n") {
+					if line = strings.TrimRight(line, " \t"); line != "" {
+						sb.WriteString("  " + md(line))
+					}
+					sb.WriteByte('\n')
+				}
+				sb.WriteByte('\n')
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<section class="arch-log">
+<h1>{{ .Title }}</h1>
+{{- range .Releases }}
+<h2>{{ .Title }}{{ with .Info }} <small>({{ . }})</small>{{ end }}</h2>
+<ul>
+{{- range .Changes }}
+<li><a href="{{ .URL }}"><code>{{ .ShortId }}</code></a> {{ .Summary }}
+{{- with .Author }} &mdash; <em>{{ . }}</em>{{ end }}{{ with .Date }}, {{ . }}{{ end }}
+{{- with .Message }}<pre>{{ . }}</pre>{{ end }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</section>
+`))
+
+func formatHTML(w io.Writer, res logResult) error {
+	return htmlTemplate.Execute(w, toChangelog(res))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+// command is a subcommand given instead of a package, e.g. 'arch-log cache clear'.
+// To query a package named like a command, prefix it with its repo, e.g. 'extra/cache'.
+type command struct {
+	name string
+	run  func(ctx context.Context, args []string) error
+	// server is set for commands running until interrupted, which is their regular end.
+	// They apply '--timeout' on their own.
+	server bool
+}
+
+var commands = []command{
+	{"cache", runCache, false},
+	{"prefetch", runPrefetch, false},
+	{"feed", runFeed, false},
+	{"serve", runServe, true},
+}
+
+func lookupCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func runCache(_ context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: cache clear|dir")
+	}
+
+	cache := client.Cache()
+	if cache == nil {
+		dir, err := http.DefaultCacheDir()
+		if err != nil {
+			return err
+		}
+		cache = http.NewCache(dir)
+	}
+
+	switch args[0] {
+	case "clear":
+		log.Debugf("Removing cached responses from '%s'", cache.Dir())
+		if err := cache.Clear(); err != nil {
+			return fmt.Errorf("clearing cache: %w", err)
+		}
+		log.Printf("Cleared cache in '%s'.", cache.Dir())
+		return nil
+	case "dir":
+		fmt.Println(cache.Dir())
+		return nil
+	default:
+		return fmt.Errorf("unknown cache command '%s'", args[0])
+	}
+}
+
+// prefetch fetches log and PKGBUILD of the package, so that they are available offline afterwards.
+func prefetch(ctx context.Context, spec archlog.Spec) error {
+	opts := logOptions()
+	opts.Patches = options.patch
+	res, err := fetchLogResult(ctx, spec, opts)
+	if err != nil {
+		return err
+	}
+
+	p := res.provider
+	body, err := p.GetPkgBuild(client.Context(ctx), spec.Name, spec.Repo, "")
+	if err != nil {
+		return fmt.Errorf("fetching PKGBUILD from %s: %w", p.Name(), err)
+	}
+	defer body.Close()
+
+	if _, err = io.Copy(io.Discard, body); err != nil {
+		return fmt.Errorf("fetching PKGBUILD from %s: %w", p.Name(), err)
+	}
+
+	log.From(ctx).Printf("Prefetched '%s' from %s.", spec, p.Name())
+	return nil
+}
+
+func runPrefetch(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: prefetch <pkg>...")
+	}
+
+	if cache := client.Cache(); cache == nil || cache.Offline {
+		return errors.New("'prefetch' needs the HTTP cache and network access")
+	}
+
+	return runJobs(ctx, args, "prefetching", func(ctx context.Context, i int, _ io.Writer) error {
+		spec, err := parsePackage(args[i])
+		if err != nil {
+			return err
+		}
+		return prefetch(ctx, spec)
+	})
+}
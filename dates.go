@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var relativeDateRe = regexp.MustCompile(`^(\d+)\s*(second|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// absolute date layouts, together with the length of the period they denote (nil for a point in time)
+var dateLayouts = []struct {
+	layout string
+	period func(time.Time) time.Time
+}{
+	{time.RFC3339, nil},
+	{"2006-01-02T15:04:05", nil},
+	{"2006-01-02 15:04:05", nil},
+	{"2006-01-02T15:04", nil},
+	{"2006-01-02 15:04", nil},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// parseDate parses an absolute date (e.g. "2024-01-15" or "2024-01") or a relative one (e.g. "2 weeks ago",
+// "yesterday"). As a date may denote a period, like a day or a month, its start and (exclusive) end are
+// returned. For a point in time, both are the same.
+func parseDate(s string, now time.Time) (start, end time.Time, err error) {
+	date := strings.ToLower(strings.TrimSpace(s))
+
+	switch date {
+	case "now":
+		return now, now, nil
+	case "today":
+		start = startOfDay(now)
+		return start, start.AddDate(0, 0, 1), nil
+	case "yesterday":
+		end = startOfDay(now)
+		return end.AddDate(0, 0, -1), end, nil
+	}
+
+	if m := relativeDateRe.FindStringSubmatch(date); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return start, end, fmt.Errorf("invalid date '%s': %w", s, err)
+		}
+
+		switch m[2] {
+		case "second":
+			start = now.Add(-time.Duration(n) * time.Second)
+		case "minute":
+			start = now.Add(-time.Duration(n) * time.Minute)
+		case "hour":
+			start = now.Add(-time.Duration(n) * time.Hour)
+		case "day":
+			start = now.AddDate(0, 0, -n)
+		case "week":
+			start = now.AddDate(0, 0, -7*n)
+		case "month":
+			start = now.AddDate(0, -n, 0)
+		case "year":
+			start = now.AddDate(-n, 0, 0)
+		}
+		return start, start, nil
+	}
+
+	for _, l := range dateLayouts {
+		if t, err := time.ParseInLocation(l.layout, strings.ToUpper(date), now.Location()); err == nil {
+			if l.period == nil {
+				return t, t, nil
+			}
+			return t, l.period(t), nil
+		}
+	}
+
+	return start, end, fmt.Errorf("invalid date '%s', expected e.g. '2024-01-15', '2024-01', 'yesterday', or '2 weeks ago'", s)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/entries"
+)
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	NS        string      `xml:"xmlns,attr"`
+	Id        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Id       string         `xml:"id"`
+	Title    string         `xml:"title"`
+	Link     atomLink       `xml:"link"`
+	Updated  string         `xml:"updated"`
+	Author   atomAuthor     `xml:"author"`
+	Category []atomCategory `xml:"category"`
+	Content  *atomContent   `xml:"content,omitempty"`
+}
+
+type feedItem struct {
+	res    *logResult
+	change entries.Change
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func toAtomEntry(item feedItem) atomEntry {
+	c := item.change
+	url := item.res.provider.CommitURL(item.res.PkgBase, c.Id)
+
+	title := item.res.PkgBase + ": " + c.Summary
+	if c.Tag != "" {
+		title += " (" + c.Tag + ")"
+	}
+
+	author := c.Author
+	if author == "" {
+		author = "unknown"
+	}
+
+	entry := atomEntry{
+		Id:       url,
+		Title:    title,
+		Link:     atomLink{url},
+		Updated:  atomTime(c.CommitTime),
+		Author:   atomAuthor{author},
+		Category: []atomCategory{{item.res.PkgBase}},
+	}
+
+	if msg := strings.TrimSpace(c.Message); msg != "" {
+		entry.Content = &atomContent{"text", msg}
+	}
+	return entry
+}
+
+// writeFeed writes the changes of all logs as one Atom feed, newest first.
+func writeFeed(w io.Writer, title string, logs []logResult) error {
+	var items []feedItem
+	for i := range logs {
+		for _, c := range logs[i].Changes {
+			items = append(items, feedItem{&logs[i], c})
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b feedItem) int {
+		return b.change.CommitTime.Compare(a.change.CommitTime)
+	})
+
+	feed := atomFeed{
+		NS:        atomNS,
+		Id:        "urn:arch-log:feed:" + strings.ReplaceAll(title, " ", ""),
+		Title:     PROG_NAME + ": " + title,
+		Generator: PROG_NAME + " " + archlog.Version,
+		Updated:   atomTime(time.Now()),
+	}
+
+	if len(items) > 0 && !items[0].change.CommitTime.IsZero() {
+		feed.Updated = atomTime(items[0].change.CommitTime)
+	}
+
+	for _, item := range items {
+		feed.Entries = append(feed.Entries, toAtomEntry(item))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// fetchLogResult fetches the log of the package, without formatting the changes.
+func fetchLogResult(ctx context.Context, spec archlog.Spec, opts archlog.LogOptions) (logResult, error) {
+	l, err := client.LogSpec(ctx, spec, opts)
+	if err != nil {
+		return logResult{}, notCachedHint(spec, err)
+	}
+
+	return logResult{l.Provider, l.Log}, nil
+}
+
+// runFeed writes a merged Atom feed of the changes of all given packages.
+// Packages that cannot be fetched are reported, but do not prevent the feed from being written.
+func runFeed(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: feed <pkg>...")
+	}
+
+	logs := make([]logResult, len(args))
+	fetched := make([]bool, len(args))
+
+	jobErr := runJobs(ctx, args, "fetching the log", func(ctx context.Context, i int, _ io.Writer) error {
+		spec, err := parsePackage(args[i])
+		if err != nil {
+			return err
+		}
+
+		if logs[i], err = fetchLogResult(ctx, spec, logOptions()); err != nil {
+			return err
+		}
+		logs[i].Changes = selectEntries(logs[i].Changes, options.number)
+		fetched[i] = true
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return jobErr
+	}
+
+	var available []logResult
+	for i, l := range logs {
+		if fetched[i] {
+			available = append(available, l)
+		}
+	}
+
+	if err := writeFeed(os.Stdout, strings.Join(args, ", "), available); err != nil {
+		return fmt.Errorf("writing feed: %w", err)
+	}
+	return jobErr
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+)
+
+func compileFilterRe(flag, expr string) (*regexp.Regexp, error) {
+	if options.ignoreCase {
+		expr = "(?i)" + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression for '--%s': %w", flag, err)
+	}
+	return re, nil
+}
+
+// buildFilter combines '--author', '--grep', and '--only-tagged' into a filter on the changes.
+// It returns nil if no filter is given.
+func buildFilter() (func(entries.Change) bool, error) {
+	var filters []func(entries.Change) bool
+
+	if options.author != "" {
+		re, err := compileFilterRe("author", options.author)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, func(c entries.Change) bool {
+			return re.MatchString(c.Author)
+		})
+	}
+
+	if options.grep != "" {
+		re, err := compileFilterRe("grep", options.grep)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, func(c entries.Change) bool {
+			matches := re.MatchString(c.Summary) || re.MatchString(c.Message)
+			return matches != options.invertGrep
+		})
+	}
+
+	if options.onlyTagged {
+		filters = append(filters, func(c entries.Change) bool {
+			return c.Tag != ""
+		})
+	}
+
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	return func(c entries.Change) bool {
+		for _, f := range filters {
+			if !f(c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	flag "github.com/spf13/pflag"
+
+	"github.com/Necoro/arch-log/pkg/diff"
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+// logResult is the log of a package together with the provider it has been fetched from.
+type logResult struct {
+	provider provider.Provider
+	entries.Log
+}
+
+type formatter func(w io.Writer, res logResult) error
+
+var formatters = map[string]formatter{
+	"short":    formatShort,
+	"long":     formatLong,
+	"json":     formatJSON,
+	"ndjson":   formatNDJSON,
+	"markdown": formatMarkdown,
+	"html":     formatHTML,
+}
+
+func formatNames() string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+func lookupFormatter(name string) (formatter, error) {
+	if f, ok := formatters[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown format '%s' (available: %s)", name, formatNames())
+}
+
+// selectFormatter determines the formatter from the '--format', '--long', and '--template' options.
+func selectFormatter() (formatter, error) {
+	formatChanged := flag.CommandLine.Changed("format")
+
+	if options.template != "" {
+		if formatChanged || options.longLog || options.byRelease {
+			return nil, errors.New("'--template' cannot be combined with '--format', '--long', or '--by-release'")
+		}
+		return templateFormatter(options.template)
+	}
+
+	if options.patch && !options.longLog {
+		if formatChanged && options.format != "long" {
+			return nil, fmt.Errorf("'--patch' cannot be combined with '--format=%s'", options.format)
+		}
+		options.format = "long"
+	}
+
+	if options.longLog {
+		if formatChanged && options.format != "long" {
+			return nil, fmt.Errorf("conflicting formats specified: '--long' vs '--format=%s'", options.format)
+		}
+		options.format = "long"
+	}
+
+	f, err := lookupFormatter(options.format)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.byRelease {
+		switch options.format {
+		case "short", "long":
+			f = byRelease(f)
+		case "markdown", "html":
+			// always grouped by release
+		default:
+			return nil, fmt.Errorf("'--by-release' cannot be combined with '--format=%s'", options.format)
+		}
+	}
+
+	return f, nil
+}
+
+// sortedReleases groups the changes, which are sorted according to '--reverse', by release.
+// The releases and the changes inside are sorted the same way.
+func sortedReleases(changes []entries.Change) []entries.Release {
+	if options.reverse {
+		return entries.Releases(changes)
+	}
+
+	newestFirst := slices.Clone(changes)
+	slices.Reverse(newestFirst)
+
+	releases := entries.Releases(newestFirst)
+	slices.Reverse(releases)
+	for _, r := range releases {
+		slices.Reverse(r.Changes)
+	}
+
+	return releases
+}
+
+// byRelease wraps the formatter, so that the changes are shown under the heading of their release.
+func byRelease(f formatter) formatter {
+	return func(w io.Writer, res logResult) error {
+		for i, r := range sortedReleases(res.Changes) {
+			sep := ""
+			if i > 0 {
+				sep = "\n"
+			}
+			if _, err := fmt.Fprint(w, sep, r.Heading(), "\n"); err != nil {
+				return err
+			}
+
+			// tag and repo are part of the heading
+			changes := make([]entries.Change, len(r.Changes))
+			for j, c := range r.Changes {
+				c.Tag = ""
+				c.RepoInfo = ""
+				changes[j] = c
+			}
+
+			release := res
+			release.Changes = changes
+			if err := f(w, release); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+var headerColor = color.New(color.FgBlue, color.Bold)
+
+// printHeader prints a heading for a package's log, unless the output is meant to be machine-readable
+// or the format brings its own heading.
+func printHeader(w io.Writer, title string) error {
+	if options.template == "" {
+		switch options.format {
+		case "json", "ndjson", "markdown", "html":
+			return nil
+		}
+	}
+
+	_, err := fmt.Fprintln(w, headerColor.Sprint("==> "+title))
+	return err
+}
+
+func formatShort(w io.Writer, res logResult) error {
+	maxTL := maxTagLength(res.Changes)
+	maxRL := maxRepoLength(res.Changes)
+
+	for _, c := range res.Changes {
+		if _, err := fmt.Fprintln(w, c.ShortFormat(maxTL, maxRL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatLong(w io.Writer, res logResult) error {
+	for _, c := range res.Changes {
+		if _, err := fmt.Fprintln(w, c.Format()); err != nil {
+			return err
+		}
+		if c.Patch != "" {
+			if _, err := fmt.Fprint(w, "\n", diff.Colorize(c.Patch)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, "--------------"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSON schema of a single change. See the manual for the documentation.
+type jsonChange struct {
+	Id         string  `json:"id"`
+	CommitTime *string `json:"commit_time"`
+	Summary    string  `json:"summary"`
+	Message    string  `json:"message"`
+	Author     string  `json:"author"`
+	Tag        string  `json:"tag"`
+	RepoInfo   string  `json:"repo_info"`
+}
+
+type jsonPackage struct {
+	PkgBase  string `json:"pkgbase"`
+	Provider string `json:"provider"`
+	Repo     string `json:"repo"`
+}
+
+type jsonLog struct {
+	jsonPackage
+	Entries []jsonChange `json:"entries"`
+}
+
+type jsonLine struct {
+	jsonPackage
+	jsonChange
+}
+
+func toJsonChange(c entries.Change) jsonChange {
+	var commitTime *string
+	if !c.CommitTime.IsZero() {
+		t := c.CommitTime.Format(time.RFC3339)
+		commitTime = &t
+	}
+
+	return jsonChange{
+		Id:         c.Id,
+		CommitTime: commitTime,
+		Summary:    c.Summary,
+		Message:    strings.TrimSpace(c.Message),
+		Author:     c.Author,
+		Tag:        c.Tag,
+		RepoInfo:   c.RepoInfo,
+	}
+}
+
+func toJsonPackage(res logResult) jsonPackage {
+	return jsonPackage{
+		PkgBase:  res.PkgBase,
+		Provider: res.provider.Name(),
+		Repo:     res.Repo,
+	}
+}
+
+func formatJSON(w io.Writer, res logResult) error {
+	out := jsonLog{
+		jsonPackage: toJsonPackage(res),
+		Entries:     make([]jsonChange, len(res.Changes)),
+	}
+
+	for i, c := range res.Changes {
+		out.Entries[i] = toJsonChange(c)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(out)
+}
+
+func formatNDJSON(w io.Writer, res logResult) error {
+	pkg := toJsonPackage(res)
+
+	e := json.NewEncoder(w)
+	for _, c := range res.Changes {
+		if err := e.Encode(jsonLine{pkg, toJsonChange(c)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 
+	"github.com/Necoro/arch-log/pkg/archlog"
 	"github.com/Necoro/arch-log/pkg/entries"
 	"github.com/Necoro/arch-log/pkg/log"
-	"github.com/Necoro/arch-log/pkg/provider/arch"
-	"github.com/Necoro/arch-log/pkg/provider/aur"
+	"github.com/Necoro/arch-log/pkg/provider"
 )
 
 func maxLength(f func(entries.Change) string) func(changes []entries.Change) int {
@@ -32,81 +35,117 @@ var maxRepoLength = maxLength(func(change entries.Change) string {
 	return change.RepoInfo
 })
 
-func formatEntryList(changes []entries.Change) {
+// selectEntries sorts the changes and cuts them to the given number, 0 for all.
+func selectEntries(changes []entries.Change, number int) []entries.Change {
 	log.Debugf("Received entries: %+v", changes)
 
 	sort.SliceStable(changes, func(i, j int) bool {
 		return timeLess(changes[i].CommitTime, changes[j].CommitTime)
 	})
 
-	if len(changes) > options.number {
+	if number > 0 && len(changes) > number {
 		if options.reverse {
-			changes = changes[:options.number]
+			changes = changes[:number]
 		} else {
-			rest := len(changes) - options.number
+			rest := len(changes) - number
 			changes = changes[rest:]
 		}
 	}
 
-	maxTL := maxTagLength(changes)
-	maxRL := maxRepoLength(changes)
+	return changes
+}
 
-	for _, c := range changes {
-		if !options.longLog {
-			fmt.Println(c.ShortFormat(maxTL, maxRL))
-		} else {
-			fmt.Println(c.Format())
-			fmt.Println("--------------")
-		}
+// formatEntryList writes the log, cut to the given number of changes, 0 for all.
+func formatEntryList(w io.Writer, res logResult, number int) error {
+	res.Changes = selectEntries(res.Changes, number)
+
+	if err := format(w, res); err != nil {
+		return fmt.Errorf("writing log: %w", err)
 	}
+	return nil
 }
 
-func handleEntries(what string, pkg string, repo string, f func(string, string) ([]entries.Change, error)) (bool, error) {
-	log.Debug("Checking ", what)
-
-	if changes, err := f(pkg, repo); err == nil {
-		formatEntryList(changes)
-		return false, nil
-	} else if errors.Is(err, entries.ErrNotFound) {
-		log.Debug("Not found on ", what)
-		return true, nil
-	} else {
-		return false, fmt.Errorf("error fetching from %s: %w", what, err)
+// applySinceInstalled sets the lower bound of the query to the installed version, if requested by '--since-installed'.
+func applySinceInstalled(ctx context.Context, spec archlog.Spec, opts *archlog.LogOptions) error {
+	if !options.sinceInstalled {
+		return nil
 	}
+
+	installed, err := installedVersion(spec.Name)
+	if err != nil {
+		return err
+	}
+	log.From(ctx).Debugf("Showing changes since installed version '%s'", installed)
+	opts.From = installed
+	return nil
 }
 
-func notFoundError(pkg string) error {
-	var msg string
-	switch {
-	case options.aur:
-		msg = "could not be found on AUR"
-	case options.arch:
-		msg = "could not be found on Arch"
-	default:
-		msg = "could neither be found on Arch nor AUR"
+func fetchLog(ctx context.Context, w io.Writer, spec archlog.Spec) error {
+	opts := logOptions()
+	if err := applySinceInstalled(ctx, spec, &opts); err != nil {
+		return err
 	}
 
-	return fmt.Errorf("package '%s' %s", pkg, msg)
+	return fetchLogWith(ctx, w, spec, opts)
 }
 
-func fetchLog(pkg string) (err error) {
-	var notfound bool
+// fetchLogWith shows the log of the package with the given selection of changes, and their patches if requested.
+func fetchLogWith(ctx context.Context, w io.Writer, spec archlog.Spec, opts archlog.LogOptions) error {
+	opts.Patches = options.patch
+	res, err := fetchLogResult(ctx, spec, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Patches && !res.provider.Capabilities().Has(provider.Patches) {
+		log.From(ctx).Warnf("Showing patches is not supported by %s.", res.provider.Name())
+	}
+
+	return formatEntryList(w, res, opts.Limit)
+}
 
-	if !options.aur {
-		if notfound, err = handleEntries("Arch", pkg, options.repo, arch.GetEntries); err != nil {
-			return
+// fetchLogs shows the logs of the given packages. With more than one package, each log
+// gets its own heading and the packages are fetched concurrently.
+func fetchLogs(ctx context.Context, args []string) error {
+	switch len(args) {
+	case 0:
+		return errors.New("no package specified")
+	case 1:
+		spec, err := parsePackage(args[0])
+		if err != nil {
+			return err
 		}
+		return fetchLog(ctx, os.Stdout, spec)
 	}
 
-	if options.aur || (notfound && !options.arch) {
-		if notfound, err = handleEntries("AUR", pkg, options.repo, aur.GetEntries); err != nil {
-			return
+	return runLogJobs(ctx, args, func(ctx context.Context, i int, w io.Writer) error {
+		if err := printHeader(w, args[i]); err != nil {
+			return err
+		}
+
+		spec, err := parsePackage(args[i])
+		if err != nil {
+			return err
 		}
+		return fetchLog(ctx, w, spec)
+	})
+}
+
+// runLogJobs runs the jobs showing the logs of several packages. With the JSON format,
+// the objects of all packages are combined into one array.
+func runLogJobs(ctx context.Context, names []string, job func(ctx context.Context, i int, w io.Writer) error) error {
+	if options.template != "" || options.format != "json" {
+		return runJobs(ctx, names, "fetching the log", job)
 	}
 
-	if notfound {
-		return notFoundError(pkg)
+	list := &jsonList{w: os.Stdout}
+	err := runJobsTo(ctx, list, names, "fetching the log", job)
+	if ctx.Err() != nil {
+		return err
 	}
 
-	return nil
+	if closeErr := list.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("writing output: %w", closeErr)
+	}
+	return err
 }
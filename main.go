@@ -1,36 +1,71 @@
 package main
 
 import (
-	_ "embed"
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"os/signal"
 	"time"
 
 	flag "github.com/spf13/pflag"
 
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/http"
 	"github.com/Necoro/arch-log/pkg/log"
 )
 
-//go:embed VERSION
-var VERSION string
-
 const PROG_NAME = "arch-log"
 
-var versionMsg = PROG_NAME + " v" + VERSION
+var versionMsg = PROG_NAME + " v" + archlog.Version
 
 // flags
 var options struct {
-	printVersion bool
-	debug        bool
-	arch         bool
-	aur          bool
-	repo         string
-	pkgbuild     bool
-	reverse      bool
-	number       int
-	longLog      bool
+	printVersion   bool
+	debug          bool
+	arch           bool
+	aur            bool
+	repo           string
+	providers      []string
+	pkgbuild       bool
+	pkgbuildDiff   string
+	reverse        bool
+	number         int
+	longLog        bool
+	format         string
+	template       string
+	patch          bool
+	upgrades       bool
+	root           string
+	dbPath         string
+	noCache        bool
+	refresh        bool
+	cacheTTL       map[string]string
+	cacheDir       string
+	offline        bool
+	httpTimeout    time.Duration
+	retries        int
+	proxy          string
+	timeout        time.Duration
+	strategy       string
+	jobs           int
+	fromFile       string
+	from           string
+	to             string
+	sinceInstalled bool
+	since          string
+	until          string
+	sinceTime      time.Time
+	untilTime      time.Time
+	author         string
+	grep           string
+	ignoreCase     bool
+	invertGrep     bool
+	onlyTagged     bool
+	filter         func(entries.Change) bool
+	byRelease      bool
+	listen         string
 }
 
 func init() {
@@ -38,16 +73,139 @@ func init() {
 	flag.BoolVarP(&options.debug, "debug", "d", false, "enable debug output")
 	flag.BoolVar(&options.arch, "arch", false, "force usage of Arch git")
 	flag.BoolVar(&options.aur, "aur", false, "force usage of AUR")
+	flag.StringSliceVar(&options.providers, "provider", nil, "force usage of the given provider (may be repeated)")
+	flag.StringVar(&options.from, "from", "", "only show the changes after the given version")
+	flag.StringVar(&options.to, "to", "", "only show the changes up to (and including) the given version")
+	flag.BoolVar(&options.sinceInstalled, "since-installed", false, "only show the changes after the installed version")
+	flag.StringVar(&options.since, "since", "", "only show the changes since the given date, e.g. '2024-01' or '2 weeks ago'")
+	flag.StringVar(&options.until, "until", "", "only show the changes before the end of the given date, e.g. '2024-01-15' or 'yesterday'")
+	flag.StringVar(&options.author, "author", "", "only show the changes whose author matches the regular expression")
+	flag.StringVar(&options.grep, "grep", "", "only show the changes whose message matches the regular expression")
+	flag.BoolVarP(&options.ignoreCase, "regexp-ignore-case", "i", false, "match '--author' and '--grep' case-insensitively")
+	flag.BoolVar(&options.invertGrep, "invert-grep", false, "only show the changes whose message does not match '--grep'")
+	flag.BoolVar(&options.onlyTagged, "only-tagged", false, "only show the changes carrying a release tag")
+	flag.BoolVar(&options.byRelease, "by-release", false, "group the changes by release, like a changelog")
 	flag.BoolVarP(&options.reverse, "reverse", "r", false, "reverse order of commits")
-	flag.IntVarP(&options.number, "number", "n", 10, "max number of commits to show")
-	flag.BoolVarP(&options.longLog, "long", "l", false, "slightly verbose log messages")
+	flag.IntVarP(&options.jobs, "jobs", "j", 4, "number of packages fetched at the same time")
+	flag.IntVarP(&options.number, "number", "n", 10, "max number of commits to show, 0 for all")
+	flag.BoolVarP(&options.longLog, "long", "l", false, "slightly verbose log messages (same as '--format=long')")
+	flag.StringVarP(&options.format, "format", "f", "short", "output format of the log: short, long, json, ndjson, markdown, or html")
+	flag.StringVarP(&options.template, "template", "t", "", "format the log using a Go template or one of the built-in templates: oneline, medium, full")
+	flag.BoolVarP(&options.patch, "patch", "P", false, "show the diff of each commit (implies '--long')")
+	flag.BoolVarP(&options.upgrades, "upgrades", "u", false, "show the changes of all pending upgrades instead of a single package")
+	flag.StringVar(&options.root, "root", "/", "installation root, used to find the pacman databases")
+	flag.StringVar(&options.dbPath, "dbpath", "", "pacman database path (default: <root>/var/lib/pacman)")
 	flag.StringVar(&options.repo, "repo", "", "restrict to repo (e.g. \"extra\")")
 	flag.BoolVarP(&options.pkgbuild, "pkgbuild", "p", false, "show PKGBUILD instead of the log (honors PAGER)")
+	flag.StringVar(&options.pkgbuildDiff, "pkgbuild-diff", "", "show diff of the PKGBUILD between two versions or repos, e.g. '1.2-1..1.3-2' (honors PAGER)")
+	flag.StringVar(&options.fromFile, "from-file", "", "read the packages from the given file ('-' for stdin), one per line")
+	flag.BoolVar(&options.noCache, "no-cache", false, "do not use the HTTP cache")
+	flag.BoolVar(&options.refresh, "refresh", false, "revalidate all cached HTTP responses")
+	flag.StringVar(&options.cacheDir, "cache-dir", "", "directory of the HTTP cache, e.g. a local mirror (default: $XDG_CACHE_HOME/arch-log)")
+	flag.BoolVar(&options.offline, "offline", false, "answer all queries from the HTTP cache, never access the network")
+	flag.DurationVar(&options.httpTimeout, "http-timeout", 30*time.Second, "timeout of a single HTTP request, 0 for none")
+	flag.StringVar(&options.strategy, "strategy", string(archlog.Sequential), "how to query the providers: 'sequential' or 'race' (all at once)")
+	flag.DurationVar(&options.timeout, "timeout", 0, "deadline for the whole run, e.g. '1m', 0 for none")
+	flag.IntVar(&options.retries, "retries", 3, "number of retries of failed HTTP requests")
+	flag.StringVar(&options.proxy, "proxy", "", "URL of the HTTP proxy, 'direct' for none (default: taken from HTTP_PROXY etc.)")
+	flag.StringVar(&options.listen, "listen", "localhost:8080", "address the 'serve' command listens on")
+	flag.StringToStringVar(&options.cacheTTL, "cache-ttl", nil, "time to live of cached responses per endpoint class, e.g. 'history=5m,search=1h'")
 }
 
 var timeLess = time.Time.Before
 
-func parseFlags() (string, error) {
+// errInterrupted is returned when the run has been cancelled by SIGINT
+var errInterrupted = errors.New("interrupted")
+
+// errQuit signals that the program should exit without doing anything (e.g. after printing the help)
+var errQuit = errors.New("quit")
+
+var format formatter
+
+// client queries the providers, as configured by the flags
+var client *archlog.Client
+
+func setupClient() error {
+	strategy, err := archlog.ParseStrategy(options.strategy)
+	if err != nil {
+		return err
+	}
+
+	providers, err := forcedProviders()
+	if err != nil {
+		return err
+	}
+
+	httpClient, err := http.NewClient(http.Config{
+		Timeout:   options.httpTimeout,
+		Retries:   options.retries,
+		UserAgent: archlog.UserAgent,
+		Proxy:     options.proxy,
+	})
+	if err != nil {
+		return err
+	}
+
+	cache, err := setupCache()
+	if err != nil {
+		return err
+	}
+
+	db := pacmanDB()
+	client, err = archlog.NewClient(archlog.Options{
+		HTTP:      httpClient,
+		Cache:     cache,
+		Providers: providers,
+		Logger:    log.Default,
+		DB:        &db,
+		Strategy:  strategy,
+	})
+	return err
+}
+
+// setupCache returns the HTTP cache as configured by the flags, nil if it is disabled.
+func setupCache() (*http.Cache, error) {
+	if options.noCache {
+		if options.offline {
+			return nil, errors.New("'--offline' cannot be combined with '--no-cache'")
+		}
+
+		log.Debug("HTTP cache disabled")
+		return nil, nil
+	}
+
+	dir := options.cacheDir
+	if dir == "" {
+		var err error
+		if dir, err = http.DefaultCacheDir(); err != nil {
+			if options.offline {
+				return nil, fmt.Errorf("cannot determine cache directory: %w", err)
+			}
+
+			log.Warnf("Cannot determine cache directory, disabling HTTP cache: %v", err)
+			return nil, nil
+		}
+	}
+
+	cache := http.NewCache(dir)
+	cache.Refresh = options.refresh
+	cache.Offline = options.offline
+
+	for class, value := range options.cacheTTL {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL for '%s': %w", class, err)
+		}
+		if err = cache.SetTTL(http.Class(class), ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debugf("Using HTTP cache in '%s'", dir)
+	return cache, nil
+}
+
+func parseFlags() error {
 	// overwrite errorHandling mode
 	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 
@@ -57,12 +215,12 @@ func parseFlags() (string, error) {
 			flag.Usage()
 		}
 
-		return "", nil
+		return errQuit
 	}
 
 	if options.printVersion {
 		println(versionMsg)
-		return "", nil
+		return errQuit
 	}
 
 	if options.debug {
@@ -73,58 +231,155 @@ func parseFlags() (string, error) {
 		timeLess = time.Time.After
 	}
 
-	pkg := flag.Arg(0)
-	if pkg == "" {
-		return "", errors.New("no package specified")
+	var err error
+	if format, err = selectFormatter(); err != nil {
+		return err
 	}
 
-	if idx := strings.IndexRune(pkg, '/'); idx > -1 {
-		repo := pkg[:idx]
-		pkg = pkg[idx+1:]
+	if options.pkgbuildDiff != "" {
+		if _, _, err = parseDiffRange(options.pkgbuildDiff); err != nil {
+			return err
+		}
+	}
 
-		log.Debugf("Split package name into repo '%s' and pkg '%s'.", repo, pkg)
+	if err = setupClient(); err != nil {
+		return err
+	}
+
+	if flag.NArg() == 1 && flag.Arg(0) == "-" {
+		options.fromFile = "-"
+	}
+
+	if options.filter, err = buildFilter(); err != nil {
+		return err
+	}
+
+	if options.sinceInstalled && options.from != "" {
+		return errors.New("'--since-installed' cannot be combined with '--from'")
+	}
 
-		if options.repo == "" {
-			options.repo = repo
-		} else if options.repo != repo {
-			return "", fmt.Errorf("conflicting repos specified: '%s' vs '%s'", options.repo, repo)
+	now := time.Now()
+	if options.since != "" {
+		if options.sinceTime, _, err = parseDate(options.since, now); err != nil {
+			return err
+		}
+	}
+	if options.until != "" {
+		if _, options.untilTime, err = parseDate(options.until, now); err != nil {
+			return err
 		}
 	}
 
-	if strings.ToLower(options.repo) == "aur" {
-		log.Debug("Found repo 'AUR', assuming '--aur'")
-		options.aur = true
-		options.repo = ""
-	} else if options.repo != "" {
-		log.Debug("Repo is given, assuming '--arch'")
-		options.arch = true
+	hasRange := options.from != "" || options.to != "" || options.sinceInstalled || options.since != "" || options.until != ""
+	if hasRange && !flag.CommandLine.Changed("number") {
+		// show all changes of the range
+		options.number = 0
 	}
 
-	if options.aur && options.arch {
-		log.Print("Forced both Arch and AUR, checking both.")
-		options.aur = false
-		options.arch = false
+	if options.fromFile != "" {
+		if options.upgrades {
+			return errors.New("'--upgrades' cannot be combined with a package list")
+		}
+		if flag.NArg() > 0 && flag.Arg(0) != "-" {
+			return errors.New("packages cannot be given together with a package list")
+		}
 	}
 
-	return pkg, nil
+	if options.upgrades {
+		if flag.NArg() > 0 {
+			return errors.New("'--upgrades' does not take a package")
+		}
+		if options.from != "" || options.to != "" || options.sinceInstalled {
+			return errors.New("'--upgrades' cannot be combined with '--from', '--to', or '--since-installed'")
+		}
+		if !flag.CommandLine.Changed("number") {
+			// show all changes between installed and available version
+			options.number = 0
+		}
+	}
+
+	return nil
 }
 
 func run() error {
-	pkg, err := parseFlags()
-	if err != nil || pkg == "" {
+	if err := parseFlags(); errors.Is(err, errQuit) {
+		return nil
+	} else if err != nil {
 		return err
 	}
 
-	if options.pkgbuild {
-		log.Debug("Showing PKGBUILD instead of log")
-		return fetchPkgBuild(pkg)
+	// cancel all in-flight requests on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd := lookupCommand(flag.Arg(0))
+	isServer := cmd != nil && cmd.server
+
+	if options.timeout > 0 && !isServer {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	ctx = client.Context(ctx)
+	err := runContext(ctx)
+
+	switch {
+	case isServer && err == nil:
+		// shut down by Ctrl-C
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("timeout of %v exceeded", options.timeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return errInterrupted
+	default:
+		return err
+	}
+}
+
+func runContext(ctx context.Context) error {
+	if cmd := lookupCommand(flag.Arg(0)); cmd != nil {
+		log.Debugf("Running command '%s'", cmd.name)
+		return cmd.run(ctx, flag.Args()[1:])
+	}
+
+	if options.upgrades {
+		log.Debug("Showing changes of pending upgrades")
+		return fetchUpgradeLogs(ctx)
+	}
+
+	if options.pkgbuild || options.pkgbuildDiff != "" {
+		if flag.NArg() > 1 || options.fromFile != "" {
+			return errors.New("the PKGBUILD can only be shown for a single package")
+		}
+
+		pkg, err := parsePackage(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		if options.pkgbuildDiff != "" {
+			log.Debug("Showing PKGBUILD diff instead of log")
+		} else {
+			log.Debug("Showing PKGBUILD instead of log")
+		}
+		return fetchPkgBuild(ctx, pkg)
+	}
+
+	if options.fromFile != "" {
+		log.Debugf("Reading packages from '%s'", options.fromFile)
+		return fetchListLogs(ctx, options.fromFile)
 	}
 
-	return fetchLog(pkg)
+	return fetchLogs(ctx, flag.Args())
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(); errors.Is(err, errInterrupted) {
+		log.Error("Interrupted.")
+		// conventional exit code of SIGINT
+		os.Exit(130)
+	} else if err != nil {
 		log.Error(err)
 		os.Exit(1)
 	}
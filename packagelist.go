@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// listEntry is a line of a package list: either '[repo/]pkg', 'pkg version' (e.g. from 'pacman -Q'),
+// or 'pkg old -> new' (as printed by checkupdates(8)).
+type listEntry struct {
+	arg      string
+	from, to string
+}
+
+func (e listEntry) title() string {
+	switch {
+	case e.to != "":
+		return e.arg + " " + e.from + " -> " + e.to
+	case e.from != "":
+		return e.arg + " " + e.from
+	default:
+		return e.arg
+	}
+}
+
+func parseListLine(line string) (listEntry, error) {
+	fields := strings.Fields(line)
+
+	switch {
+	case len(fields) == 1:
+		return listEntry{arg: fields[0]}, nil
+	case len(fields) == 2:
+		return listEntry{arg: fields[0], from: fields[1]}, nil
+	case len(fields) == 4 && fields[2] == "->":
+		return listEntry{arg: fields[0], from: fields[1], to: fields[3]}, nil
+	default:
+		return listEntry{}, fmt.Errorf("invalid line '%s', expected '[repo/]pkg', 'pkg version', or 'pkg old -> new'", line)
+	}
+}
+
+// readPackageList reads the entries of a package list. Empty lines and comments (starting with '#') are skipped.
+func readPackageList(r io.Reader) ([]listEntry, error) {
+	var list []listEntry
+
+	scanner := bufio.NewScanner(r)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseListLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNr, err)
+		}
+		list = append(list, entry)
+	}
+
+	return list, scanner.Err()
+}
+
+// readPackageListFile reads a package list from the given file, or from stdin if it is '-'.
+func readPackageListFile(name string) ([]listEntry, error) {
+	if name == "-" {
+		return readPackageList(os.Stdin)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list, err := readPackageList(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s': %w", name, err)
+	}
+	return list, nil
+}
+
+func fetchListLog(ctx context.Context, w io.Writer, e listEntry) error {
+	if err := printHeader(w, e.title()); err != nil {
+		return err
+	}
+
+	spec, err := parsePackage(e.arg)
+	if err != nil {
+		return err
+	}
+
+	// versions given in the list take precedence over '--from', '--to', and '--since-installed'
+	opts := logOptions()
+	if e.from != "" {
+		opts.From = e.from
+	} else if err = applySinceInstalled(ctx, spec, &opts); err != nil {
+		return err
+	}
+	if e.to != "" {
+		opts.To = e.to
+	}
+
+	if opts.From != "" && !flag.CommandLine.Changed("number") {
+		// show all changes since the given version
+		opts.Limit = 0
+	}
+
+	return fetchLogWith(ctx, w, spec, opts)
+}
+
+// fetchListLogs shows the logs of all packages of the list as one report.
+func fetchListLogs(ctx context.Context, name string) error {
+	list, err := readPackageListFile(name)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(list))
+	for i, e := range list {
+		names[i] = e.arg
+	}
+
+	return runLogJobs(ctx, names, func(ctx context.Context, i int, w io.Writer) error {
+		return fetchListLog(ctx, w, list[i])
+	})
+}
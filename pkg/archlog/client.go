@@ -0,0 +1,241 @@
+// Package archlog offers the functionality of arch-log as a library: the history and the PKGBUILD of packages
+// from the official repositories and the AUR.
+//
+// Packages are given as '[repository/]pkg', like on the command line. A repository naming a provider
+// (e.g. 'aur/yay') restricts the lookup to this provider.
+package archlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/pacman"
+	"github.com/Necoro/arch-log/pkg/provider"
+	"github.com/Necoro/arch-log/pkg/provider/arch"
+	_ "github.com/Necoro/arch-log/pkg/provider/aur"
+)
+
+// Options configures a Client. The zero value is usable.
+type Options struct {
+	// HTTP is used for all requests. Defaults to a client with a timeout of 30s and 3 retries.
+	HTTP *http.Client
+	// Cache, if set, replaces the cache of the HTTP client.
+	Cache *http.Cache
+	// Providers are queried in this order, the first one knowing the package is used.
+	// Defaults to all registered providers, in order of precedence.
+	Providers []provider.Provider
+	// Logger receives the messages of the providers and the HTTP layer. Defaults to log.Discard.
+	// A logger carried by the context of a call (see log.WithLogger) takes precedence.
+	Logger log.Logger
+	// DB is the pacman database used to resolve packages before querying archlinux.org.
+	// Defaults to the one of the running system.
+	DB *pacman.DB
+	// Strategy determines how the providers of a package are queried. Defaults to Sequential.
+	Strategy Strategy
+}
+
+// Client queries the providers for packages. It is safe for concurrent use.
+type Client struct {
+	http      *http.Client
+	providers []provider.Provider
+	logger    log.Logger
+	db        pacman.DB
+	strategy  Strategy
+}
+
+// NewClient creates a client, filling in the defaults for the options not set.
+func NewClient(opts Options) (*Client, error) {
+	client := opts.HTTP
+	if client == nil {
+		var err error
+		client, err = http.NewClient(http.Config{
+			Timeout:   30 * time.Second,
+			Retries:   3,
+			UserAgent: UserAgent,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Cache != nil {
+		withCache := *client
+		withCache.Cache = opts.Cache
+		client = &withCache
+	}
+
+	providers := opts.Providers
+	if len(providers) == 0 {
+		providers = provider.All()
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no providers registered")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Discard
+	}
+
+	db := pacman.DB{Root: "/"}
+	if opts.DB != nil {
+		db = *opts.DB
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = Sequential
+	} else if _, err := ParseStrategy(string(strategy)); err != nil {
+		return nil, err
+	}
+
+	return &Client{client, providers, logger, db, strategy}, nil
+}
+
+// Context makes the HTTP client, the logger (unless the context carries one), and the pacman database
+// available to the providers, for querying them directly.
+func (c *Client) Context(ctx context.Context) context.Context {
+	ctx = log.WithDefault(ctx, c.logger)
+	ctx = arch.WithDB(ctx, c.db)
+	return http.WithClient(ctx, c.http)
+}
+
+// Cache returns the cache of the HTTP client, nil if there is none.
+func (c *Client) Cache() *http.Cache {
+	return c.http.Cache
+}
+
+// Package is a package as resolved by a provider.
+type Package struct {
+	Provider provider.Provider
+	Name     string
+	PkgBase  string
+}
+
+// Resolve determines the provider knowing the package and its pkgbase.
+func (c *Client) Resolve(ctx context.Context, pkg string) (Package, error) {
+	spec, err := c.Spec(pkg, "")
+	if err != nil {
+		return Package{}, err
+	}
+
+	p, base, err := Lookup(ctx, c, spec, func(ctx context.Context, p provider.Provider) (string, error) {
+		return p.ResolveBase(ctx, spec.Name, spec.Repo)
+	})
+	if err != nil {
+		return Package{}, err
+	}
+
+	return Package{p, spec.Name, base}, nil
+}
+
+// LogOptions selects the changes returned by Client.Log. The zero value selects the complete history.
+type LogOptions struct {
+	// Repo restricts the lookup to the repository.
+	Repo string
+	// Limit is the maximum number of changes, 0 for all.
+	Limit int
+	// From and To select the changes of the releases newer than From, up to and including To.
+	// Either may be empty.
+	From, To string
+	// Since and Until select the changes in [Since, Until). Either may be zero.
+	Since, Until time.Time
+	// Filter, if set, selects the changes to return.
+	Filter func(entries.Change) bool
+	// Patches requests the diff of each change. It is left empty for providers not supporting it.
+	Patches bool
+}
+
+// Log is the history of a package, newest change first.
+type Log struct {
+	entries.Log
+	Provider provider.Provider
+}
+
+// Log returns the history of the package.
+func (c *Client) Log(ctx context.Context, pkg string, opts LogOptions) (Log, error) {
+	spec, err := c.Spec(pkg, opts.Repo)
+	if err != nil {
+		return Log{}, err
+	}
+
+	return c.LogSpec(ctx, spec, opts)
+}
+
+// LogSpec is Log for a package already split by Spec. The repo of the spec is used instead of the one of the options.
+func (c *Client) LogSpec(ctx context.Context, spec Spec, opts LogOptions) (Log, error) {
+	q := provider.Query{
+		Pkg:    spec.Name,
+		Repo:   spec.Repo,
+		Limit:  opts.Limit,
+		From:   opts.From,
+		To:     opts.To,
+		Since:  opts.Since,
+		Until:  opts.Until,
+		Filter: opts.Filter,
+	}
+
+	ctx = c.Context(ctx)
+	p, l, err := Lookup(ctx, c, spec, func(ctx context.Context, p provider.Provider) (entries.Log, error) {
+		return p.GetEntries(ctx, q)
+	})
+	if err != nil {
+		return Log{}, err
+	}
+
+	slices.SortStableFunc(l.Changes, func(a, b entries.Change) int {
+		return b.CommitTime.Compare(a.CommitTime)
+	})
+	if opts.Limit > 0 && len(l.Changes) > opts.Limit {
+		l.Changes = l.Changes[:opts.Limit]
+	}
+
+	if opts.Patches && p.Capabilities().Has(provider.Patches) {
+		for i, change := range l.Changes {
+			if l.Changes[i].Patch, err = p.GetPatch(ctx, l.PkgBase, change.Id); err != nil {
+				return Log{}, fmt.Errorf("fetching patch of commit %s: %w", change.Id, err)
+			}
+		}
+	}
+
+	return Log{l, p}, nil
+}
+
+// PkgBuild is the content of a PKGBUILD.
+type PkgBuild struct {
+	Provider provider.Provider
+	// Ref is the version, commit, or repository the PKGBUILD has been requested for. Empty for the current one.
+	Ref     string
+	Content string
+}
+
+// PKGBUILD returns the PKGBUILD of the package at the given ref, which is either empty for the current version,
+// a version, a commit id, or the name of a repository.
+func (c *Client) PKGBUILD(ctx context.Context, pkg, ref string) (PkgBuild, error) {
+	spec, err := c.Spec(pkg, "")
+	if err != nil {
+		return PkgBuild{}, err
+	}
+
+	p, body, err := Lookup(ctx, c, spec, func(ctx context.Context, p provider.Provider) (io.ReadCloser, error) {
+		return p.GetPkgBuild(ctx, spec.Name, spec.Repo, ref)
+	})
+	if err != nil {
+		return PkgBuild{}, err
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return PkgBuild{}, fmt.Errorf("reading PKGBUILD: %w", err)
+	}
+
+	return PkgBuild{p, ref, string(content)}, nil
+}
@@ -0,0 +1,343 @@
+package archlog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/pacman"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+var errBroken = errors.New("broken")
+
+// fakeProvider knows the packages of its log. If delay is set, it answers only after it;
+// if block is set, it does not answer at all until its query is cancelled.
+type fakeProvider struct {
+	name    string
+	caps    provider.Capability
+	log     entries.Log
+	err     error
+	delay   time.Duration
+	block   bool
+	queried chan struct{}
+	// contexts of the queries, to check their cancellation
+	ctxs chan context.Context
+}
+
+func newFake(name, base string, changes ...entries.Change) *fakeProvider {
+	return &fakeProvider{
+		name:    name,
+		log:     entries.Log{PkgBase: base, Changes: changes},
+		queried: make(chan struct{}, 10),
+		ctxs:    make(chan context.Context, 10),
+	}
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Capabilities() provider.Capability {
+	return f.caps
+}
+
+func (f *fakeProvider) query(ctx context.Context, pkg string) error {
+	f.queried <- struct{}{}
+	f.ctxs <- ctx
+
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if f.err != nil {
+		return f.err
+	}
+	if f.log.PkgBase == "" || pkg != "foo" {
+		return entries.ErrNotFound
+	}
+	return nil
+}
+
+func (f *fakeProvider) ResolveBase(ctx context.Context, pkg, _ string) (string, error) {
+	if err := f.query(ctx, pkg); err != nil {
+		return "", err
+	}
+	return f.log.PkgBase, nil
+}
+
+func (f *fakeProvider) GetEntries(ctx context.Context, q provider.Query) (entries.Log, error) {
+	if err := f.query(ctx, q.Pkg); err != nil {
+		return entries.Log{}, err
+	}
+
+	l := f.log
+	l.Changes = append([]entries.Change(nil), f.log.Changes...)
+	return l, nil
+}
+
+func (f *fakeProvider) GetPkgBuild(ctx context.Context, pkg, _, ref string) (io.ReadCloser, error) {
+	if err := f.query(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("pkgname=" + pkg + " # " + f.name + " " + ref)), nil
+}
+
+func (f *fakeProvider) GetPatch(_ context.Context, _, id string) (string, error) {
+	return "patch of " + id, nil
+}
+
+func (f *fakeProvider) CommitURL(_, _ string) string {
+	return ""
+}
+
+func newTestClient(t *testing.T, strategy Strategy, providers ...provider.Provider) *Client {
+	t.Helper()
+
+	cache := http.NewCache(t.TempDir())
+	cache.Offline = true
+
+	c, err := NewClient(Options{Cache: cache, Providers: providers, Strategy: strategy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func change(id string, t time.Time) entries.Change {
+	return entries.Change{Id: id, CommitTime: t}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	for _, strategy := range []Strategy{Sequential, Race} {
+		t.Run(string(strategy), func(t *testing.T) {
+			unknown := newFake("Unknown", "")
+			first := newFake("First", "foo-first")
+			// answering faster does not matter
+			first.delay = 20 * time.Millisecond
+			second := newFake("Second", "foo-second")
+
+			c := newTestClient(t, strategy, unknown, first, second)
+			pkg, err := c.Resolve(context.Background(), "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pkg.Provider != first || pkg.PkgBase != "foo-first" || pkg.Name != "foo" {
+				t.Errorf("Resolve() = %+v, want the package of First", pkg)
+			}
+		})
+	}
+}
+
+func TestSequentialStopsAtFirst(t *testing.T) {
+	first := newFake("First", "foo")
+	second := newFake("Second", "foo")
+
+	c := newTestClient(t, Sequential, first, second)
+	if _, err := c.Resolve(context.Background(), "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(second.queried) != 0 {
+		t.Error("Second has been queried, although First knows the package")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		providers []provider.Provider
+		msg       string
+	}{
+		{[]provider.Provider{newFake("A", "")}, "package 'bar' could not be found on A"},
+		{[]provider.Provider{newFake("A", ""), newFake("B", "")}, "package 'bar' could neither be found on A nor B"},
+		{[]provider.Provider{newFake("A", ""), newFake("B", ""), newFake("C", "")},
+			"package 'bar' could not be found on any of A, B or C"},
+	}
+
+	for _, tt := range tests {
+		for _, strategy := range []Strategy{Sequential, Race} {
+			c := newTestClient(t, strategy, tt.providers...)
+
+			_, err := c.Log(context.Background(), "bar", LogOptions{})
+			if !errors.Is(err, entries.ErrNotFound) {
+				t.Errorf("%s: Log() error = %v, want it to match entries.ErrNotFound", strategy, err)
+			} else if err.Error() != tt.msg {
+				t.Errorf("%s: Log() error = %q, want %q", strategy, err, tt.msg)
+			}
+		}
+	}
+}
+
+func TestErrorAborts(t *testing.T) {
+	broken := newFake("Broken", "foo")
+	broken.err = errBroken
+	second := newFake("Second", "foo")
+
+	c := newTestClient(t, Sequential, broken, second)
+	_, err := c.Log(context.Background(), "foo", LogOptions{})
+	if !errors.Is(err, errBroken) || errors.Is(err, entries.ErrNotFound) {
+		t.Errorf("Log() error = %v, want the error of Broken", err)
+	}
+	if len(second.queried) != 0 {
+		t.Error("Second has been queried after the error of Broken")
+	}
+}
+
+func TestLog(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newFake("Fake", "foo",
+		change("a", base),
+		change("c", base.Add(2*time.Hour)),
+		change("b", base.Add(time.Hour)))
+	p.caps = provider.Patches
+
+	c := newTestClient(t, Sequential, p)
+	l, err := c.Log(context.Background(), "foo", LogOptions{Limit: 2, Patches: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Provider != p || l.PkgBase != "foo" {
+		t.Errorf("Log() = %+v, want the log of Fake", l)
+	}
+
+	// newest first, cut to the limit
+	var ids []string
+	for _, ch := range l.Changes {
+		ids = append(ids, ch.Id)
+		if ch.Patch != "patch of "+ch.Id {
+			t.Errorf("patch of %s = %q", ch.Id, ch.Patch)
+		}
+	}
+	if strings.Join(ids, ",") != "c,b" {
+		t.Errorf("Log() changes = %v, want [c b]", ids)
+	}
+}
+
+func TestLogPatchesUnsupported(t *testing.T) {
+	p := newFake("Fake", "foo", change("a", time.Now()))
+
+	c := newTestClient(t, Sequential, p)
+	l, err := c.Log(context.Background(), "foo", LogOptions{Patches: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Changes[0].Patch != "" {
+		t.Errorf("patch = %q, want none without the Patches capability", l.Changes[0].Patch)
+	}
+}
+
+// waitCancelled waits for the context of the next query of the provider to be cancelled.
+func waitCancelled(t *testing.T, p *fakeProvider) {
+	t.Helper()
+
+	select {
+	case ctx := <-p.ctxs:
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Errorf("query of %s has not been cancelled", p.name)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s has not been queried", p.name)
+	}
+}
+
+func TestRaceCancellation(t *testing.T) {
+	first := newFake("First", "foo")
+	slow := newFake("Slow", "foo")
+	slow.block = true
+
+	c := newTestClient(t, Race, first, slow)
+	build, err := c.PKGBUILD(context.Background(), "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if build.Provider != first || build.Content != "pkgname=foo # First " {
+		t.Errorf("PKGBUILD() = %+v, want the one of First", build)
+	}
+
+	// the query not needed anymore is cancelled, and so is the chosen one once its body has been read
+	waitCancelled(t, slow)
+	waitCancelled(t, first)
+}
+
+func TestSpec(t *testing.T) {
+	withRepos := newFake("WithRepos", "foo")
+	withRepos.caps = provider.Repos
+	plain := newFake("Plain", "foo")
+
+	c := newTestClient(t, Sequential, withRepos, plain)
+
+	spec, err := c.Spec("core/foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != "foo" || spec.Repo != "core" || len(spec.Providers) != 1 || spec.Providers[0] != withRepos {
+		t.Errorf("Spec() = %+v, want foo in core on WithRepos only", spec)
+	}
+	if spec.String() != "core/foo" {
+		t.Errorf("Spec().String() = %q", spec)
+	}
+
+	if spec, err = c.Spec("foo", ""); err != nil || len(spec.Providers) != 2 {
+		t.Errorf("Spec() = %+v, %v, want all providers", spec, err)
+	}
+
+	for _, tt := range []struct{ pkg, repo string }{
+		{"", ""},
+		{"extra/", ""},
+		{"core/foo", "extra"},
+	} {
+		if _, err := c.Spec(tt.pkg, tt.repo); err == nil {
+			t.Errorf("Spec(%q, %q) succeeded, want an error", tt.pkg, tt.repo)
+		}
+	}
+}
+
+func TestResolveSyncDB(t *testing.T) {
+	arch := provider.Lookup("arch")
+	if arch == nil {
+		t.Fatal("Arch provider not registered")
+	}
+
+	cache := http.NewCache(t.TempDir())
+	cache.Offline = true
+
+	c, err := NewClient(Options{
+		Cache:     cache,
+		Providers: []provider.Provider{arch},
+		DB:        &pacman.DB{Root: "../pacman/testdata/root"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// found in the sync databases, so no request is needed
+	pkg, err := c.Resolve(context.Background(), "python-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.PkgBase != "foo" {
+		t.Errorf("Resolve() pkgbase = %q, want %q", pkg.PkgBase, "foo")
+	}
+
+	// others are searched on archlinux.org, which is not possible offline
+	if _, err = c.Resolve(context.Background(), "bar"); !errors.Is(err, http.ErrNotCached) {
+		t.Errorf("Resolve() error = %v, want it to match http.ErrNotCached", err)
+	}
+}
@@ -0,0 +1,253 @@
+package archlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+// Strategy determines how the providers of a package are queried.
+type Strategy string
+
+const (
+	// Sequential queries one provider after the other, in order of precedence.
+	Sequential Strategy = "sequential"
+	// Race queries all providers concurrently, the result is still chosen by precedence.
+	Race Strategy = "race"
+)
+
+// ParseStrategy returns the strategy of the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(name); s {
+	case Sequential, Race:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown strategy '%s', expected '%s' or '%s'", name, Sequential, Race)
+	}
+}
+
+// Spec is a package to query, together with the providers to query, in order of precedence.
+type Spec struct {
+	Name string
+	// Repo restricts the lookup to the repository, empty for all.
+	Repo      string
+	Providers []provider.Provider
+}
+
+func (s Spec) String() string {
+	if s.Repo != "" {
+		return s.Repo + "/" + s.Name
+	}
+	return s.Name
+}
+
+// Spec splits a package given as '[repo/]pkg' into repo and name, and determines the providers to query.
+// The repo defaults to the given one; if both are set, they must match. A repo naming a provider
+// restricts the lookup to this provider, any other repo to the providers supporting repos.
+func (c *Client) Spec(pkg, repo string) (Spec, error) {
+	if pkgRepo, name, found := strings.Cut(pkg, "/"); found {
+		c.logger.Debugf("Split package name into repo '%s' and pkg '%s'.", pkgRepo, name)
+
+		if repo != "" && repo != pkgRepo {
+			return Spec{}, fmt.Errorf("conflicting repos specified: '%s' vs '%s'", repo, pkgRepo)
+		}
+		pkg, repo = name, pkgRepo
+	}
+
+	if pkg == "" {
+		return Spec{}, errors.New("no package specified")
+	}
+
+	if p := provider.Lookup(repo); p != nil {
+		c.logger.Debugf("Found repo '%s', assuming provider %s", repo, p.Name())
+		return Spec{pkg, "", []provider.Provider{p}}, nil
+	}
+
+	providers := c.providers
+	if repo != "" {
+		c.logger.Debugf("Repo is given, restricting to providers supporting repos")
+
+		var withRepos []provider.Provider
+		for _, p := range providers {
+			if p.Capabilities().Has(provider.Repos) {
+				withRepos = append(withRepos, p)
+			}
+		}
+
+		if len(withRepos) == 0 {
+			return Spec{}, fmt.Errorf("restricting to repo '%s' is not supported by %s", repo, providerNames(providers, "and"))
+		}
+		providers = withRepos
+	}
+
+	c.logger.Debugf("Using providers: %s", providerNames(providers, "and"))
+
+	return Spec{pkg, repo, providers}, nil
+}
+
+func providerNames(ps []provider.Provider, conj string) string {
+	names := make([]string, len(ps))
+	for i, p := range ps {
+		names[i] = p.Name()
+	}
+
+	switch len(names) {
+	case 0:
+		return "no provider"
+	case 1:
+		return names[0]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + " " + conj + " " + names[len(names)-1]
+	}
+}
+
+// fetchError wraps an error returned by a provider.
+func fetchError(p provider.Provider, pkg string, err error) error {
+	return fmt.Errorf("error fetching '%s' from %s: %w", pkg, p.Name(), err)
+}
+
+// pkgNotFoundError is returned if none of the providers knows the package. It matches entries.ErrNotFound.
+type pkgNotFoundError struct {
+	msg string
+}
+
+func (e pkgNotFoundError) Error() string {
+	return e.msg
+}
+
+func (pkgNotFoundError) Unwrap() error {
+	return entries.ErrNotFound
+}
+
+func (s Spec) notFoundError() error {
+	var msg string
+	if len(s.Providers) == 1 {
+		msg = "could not be found on " + s.Providers[0].Name()
+	} else if len(s.Providers) == 2 {
+		msg = "could neither be found on " + providerNames(s.Providers, "nor")
+	} else {
+		msg = "could not be found on any of " + providerNames(s.Providers, "or")
+	}
+
+	return pkgNotFoundError{fmt.Sprintf("package '%s' %s", s.Name, msg)}
+}
+
+type lookupResult[T any] struct {
+	value T
+	err   error
+}
+
+// Lookup queries the providers of the package with the strategy of the client and returns the result of the first one,
+// in order of precedence, knowing the package. Any error other than entries.ErrNotFound aborts the lookup.
+// If no provider knows the package, the error matches entries.ErrNotFound.
+func Lookup[T any](ctx context.Context, c *Client, spec Spec, get func(context.Context, provider.Provider) (T, error)) (provider.Provider, T, error) {
+	ctx = c.Context(ctx)
+	if c.strategy == Race && len(spec.Providers) > 1 {
+		return lookupRace(ctx, spec, get)
+	}
+
+	logger := log.From(ctx)
+
+	var zero T
+	for _, p := range spec.Providers {
+		logger.Debugf("Checking %s", p.Name())
+
+		value, err := get(ctx, p)
+		if res, done, err := evaluate(logger, p, spec, lookupResult[T]{value, err}); done {
+			return p, res, err
+		}
+	}
+
+	return nil, zero, spec.notFoundError()
+}
+
+// lookupRace starts the queries of all providers at once. As soon as the result is determined,
+// the remaining queries are cancelled.
+// The query chosen is cancelled once its result has been closed, or right away if it needs no closing.
+func lookupRace[T any](ctx context.Context, spec Spec, get func(context.Context, provider.Provider) (T, error)) (provider.Provider, T, error) {
+	logger := log.From(ctx)
+	logger.Debugf("Racing %s", providerNames(spec.Providers, "and"))
+
+	results := make([]chan lookupResult[T], len(spec.Providers))
+	// each query has its own context: the chosen result (e.g. a PKGBUILD body) may still be read afterwards
+	cancels := make([]context.CancelFunc, len(spec.Providers))
+	for i, p := range spec.Providers {
+		var pCtx context.Context
+		pCtx, cancels[i] = context.WithCancel(ctx)
+		results[i] = make(chan lookupResult[T], 1)
+
+		go func(p provider.Provider, c chan<- lookupResult[T]) {
+			value, err := get(pCtx, p)
+			c <- lookupResult[T]{value, err}
+		}(p, results[i])
+	}
+
+	var zero T
+	for i, p := range spec.Providers {
+		if res, done, err := evaluate(logger, p, spec, <-results[i]); done {
+			for _, cancel := range cancels[i+1:] {
+				cancel()
+			}
+			go discard(results[i+1:])
+
+			return p, release(res, cancels[i]), err
+		}
+		cancels[i]()
+	}
+
+	return nil, zero, spec.notFoundError()
+}
+
+// evaluate returns whether the lookup is done with the given result, i.e. the package has been found or an error occurred.
+func evaluate[T any](logger log.Logger, p provider.Provider, spec Spec, res lookupResult[T]) (T, bool, error) {
+	if res.err == nil {
+		return res.value, true, nil
+	}
+
+	if errors.Is(res.err, entries.ErrNotFound) {
+		logger.Debugf("Not found on %s", p.Name())
+		return res.value, false, nil
+	}
+
+	return res.value, true, fetchError(p, spec.Name, res.err)
+}
+
+// cancelOnClose cancels the context of the query when the result is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// release ties the context of the chosen query to its result: a result still to be read (like a PKGBUILD body)
+// cancels it when closed, any other result is complete already, so the context is cancelled right away.
+func release[T any](value T, cancel context.CancelFunc) T {
+	if rc, ok := any(value).(io.ReadCloser); ok {
+		if wrapped, ok := any(cancelOnClose{rc, cancel}).(T); ok {
+			return wrapped
+		}
+	}
+
+	cancel()
+	return value
+}
+
+// discard waits for the results not needed anymore and closes them, if they hold resources (like a PKGBUILD body).
+func discard[T any](results []chan lookupResult[T]) {
+	for _, c := range results {
+		res := <-c
+		if closer, ok := any(res.value).(io.Closer); ok && res.err == nil {
+			closer.Close()
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package archlog
+
+// Version is the version of arch-log.
+const Version = "0.4.0"
+
+// UserAgent is sent with the requests of the default HTTP client.
+const UserAgent = "arch-log/" + Version
@@ -0,0 +1,45 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	headerColor = color.New(color.Bold)
+	hunkColor   = color.New(color.FgCyan)
+	addColor    = color.New(color.FgGreen)
+	delColor    = color.New(color.FgRed)
+)
+
+// Colorize colors a unified diff like git does.
+func Colorize(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+
+	sb := strings.Builder{}
+	for _, l := range lines {
+		line, nl := strings.CutSuffix(l, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "),
+			strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "),
+			strings.HasPrefix(line, "new file"), strings.HasPrefix(line, "deleted file"),
+			strings.HasPrefix(line, "rename "), strings.HasPrefix(line, "similarity "):
+			line = headerColor.Sprint(line)
+		case strings.HasPrefix(line, "@@"):
+			line = hunkColor.Sprint(line)
+		case strings.HasPrefix(line, "+"):
+			line = addColor.Sprint(line)
+		case strings.HasPrefix(line, "-"):
+			line = delColor.Sprint(line)
+		}
+
+		sb.WriteString(line)
+		if nl {
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// number of context lines around a change
+const context = 3
+
+type op struct {
+	kind byte // ' ', '-', or '+'
+	line string
+	// position in the old and new text, respectively
+	oldPos, newPos int
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// editScript computes a minimal edit script between a and b based on their longest common subsequence.
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i, j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, op{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	return ops
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	oldCount, newCount := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(ops[0].oldPos, oldCount), hunkRange(ops[0].newPos, newCount))
+	for _, o := range ops {
+		sb.WriteByte(o.kind)
+		sb.WriteString(o.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// Unified returns the unified diff between the texts a and b, named oldName and newName in the header.
+// It is empty if both texts are equal.
+func Unified(oldName, newName, a, b string) string {
+	ops := editScript(splitLines(a), splitLines(b))
+
+	sb := strings.Builder{}
+
+	start, end := -1, -1
+	for i, o := range ops {
+		if o.kind == ' ' {
+			continue
+		}
+
+		if start >= 0 && i-context > end {
+			// too far from the previous change: finish the hunk
+			writeHunk(&sb, ops[start:end])
+			start = -1
+		}
+
+		if start < 0 {
+			if sb.Len() == 0 {
+				sb.WriteString("--- " + oldName + "\n")
+				sb.WriteString("+++ " + newName + "\n")
+			}
+			start = max(0, i-context)
+		}
+		end = min(len(ops), i+1+context)
+	}
+
+	if start >= 0 {
+		writeHunk(&sb, ops[start:end])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// lines joins the lines, each terminated by a newline.
+func lines(l ...string) string {
+	if len(l) == 0 {
+		return ""
+	}
+	return strings.Join(l, "\n") + "\n"
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    lines("1", "2", "3"),
+			b:    lines("1", "2", "3"),
+			want: "",
+		},
+		{
+			name: "merged hunk",
+			// the changes are 5 lines apart, so their contexts overlap
+			a: lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10"),
+			b: lines("1", "2", "three", "4", "5", "6", "7", "8", "nine", "10"),
+			want: lines(
+				"--- a",
+				"+++ b",
+				"@@ -1,10 +1,10 @@",
+				" 1", " 2", "-3", "+three", " 4", " 5", " 6", " 7", " 8", "-9", "+nine", " 10"),
+		},
+		{
+			name: "separate hunks",
+			a: lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10",
+				"11", "12", "13", "14", "15", "16", "17", "18", "19", "20"),
+			b: lines("1", "two", "3", "4", "5", "6", "7", "8", "9", "10",
+				"11", "12", "13", "14", "fifteen", "16", "17", "18", "19", "20"),
+			want: lines(
+				"--- a",
+				"+++ b",
+				"@@ -1,5 +1,5 @@",
+				" 1", "-2", "+two", " 3", " 4", " 5",
+				"@@ -12,7 +12,7 @@",
+				" 12", " 13", " 14", "-15", "+fifteen", " 16", " 17", " 18"),
+		},
+		{
+			name: "insertion",
+			a:    "",
+			b:    lines("x", "y"),
+			want: lines("--- a", "+++ b", "@@ -0,0 +1,2 @@", "+x", "+y"),
+		},
+		{
+			name: "deletion",
+			a:    lines("x", "y"),
+			b:    "",
+			want: lines("--- a", "+++ b", "@@ -1,2 +0,0 @@", "-x", "-y"),
+		},
+		{
+			name: "insertion with context",
+			a:    lines("1", "2"),
+			b:    lines("1", "new", "2"),
+			want: lines("--- a", "+++ b", "@@ -1,2 +1,3 @@", " 1", "+new", " 2"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("a", "b", tt.a, tt.b); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
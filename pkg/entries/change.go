@@ -11,6 +11,9 @@ import (
 
 var ErrNotFound = errors.New("package could not be found remotely")
 
+// ErrNotInRepo is returned if the package exists, but not in the repo requested.
+var ErrNotInRepo = errors.New("not in the requested repo")
+
 var (
 	timeColor    = color.New(color.FgYellow, color.Bold)
 	summaryColor = color.New(color.Bold)
@@ -20,12 +23,24 @@ var (
 )
 
 type Change struct {
+	Id         string
 	CommitTime time.Time
 	Summary    string
 	Message    string
 	Author     string
 	Tag        string
 	RepoInfo   string
+	// Patch is the diff introduced by the change. It is only filled on request.
+	Patch string
+}
+
+// Log is the history of a package as returned by a provider.
+type Log struct {
+	PkgBase string
+	// Repo is the repository the package has been found in. It is empty if there are multiple,
+	// in which case Change.RepoInfo is set.
+	Repo    string
+	Changes []Change
 }
 
 func (c Change) formatTime(format string) string {
@@ -0,0 +1,49 @@
+package entries
+
+import "time"
+
+// Release is a group of changes that went into the same release.
+type Release struct {
+	// Tag is the version of the release. It is empty for the changes after the newest release.
+	Tag      string
+	RepoInfo string
+	// Time is the commit time of the tagged change.
+	Time    time.Time
+	Changes []Change
+}
+
+// Releases groups the changes, which must be ordered from newest to oldest, by release. A change belongs
+// to the release of the nearest tag at or before it, i.e. the tagged change is the newest one of its release.
+// The releases are ordered from newest to oldest as well.
+func Releases(changes []Change) []Release {
+	var releases []Release
+
+	for _, c := range changes {
+		if c.Tag != "" {
+			releases = append(releases, Release{Tag: c.Tag, RepoInfo: c.RepoInfo, Time: c.CommitTime})
+		} else if len(releases) == 0 {
+			releases = append(releases, Release{})
+		}
+
+		r := &releases[len(releases)-1]
+		r.Changes = append(r.Changes, c)
+	}
+
+	return releases
+}
+
+// Heading returns the title of the release, consisting of version, repo, and date.
+func (r Release) Heading() string {
+	if r.Tag == "" {
+		return tagColor.Sprint("Unreleased")
+	}
+
+	str := tagColor.Sprint(r.Tag)
+	if r.RepoInfo != "" {
+		str += " " + repoColor.Sprint("["+r.RepoInfo+"]")
+	}
+	if !r.Time.IsZero() {
+		str += " " + timeColor.Sprint(r.Time.Local().Format(time.DateOnly))
+	}
+	return str
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+// ErrNotCached is returned in offline mode for requests whose responses have not been cached before.
+var ErrNotCached = errors.New("not available offline")
+
+var defaultTTL = map[Class]time.Duration{
+	Search:    time.Hour,
+	History:   10 * time.Minute,
+	Content:   10 * time.Minute,
+	Immutable: 30 * 24 * time.Hour,
+}
+
+// Cache stores responses on disk, keyed by URL. Responses younger than the TTL of their class are
+// served directly, older ones are revalidated using 'If-None-Match' and 'If-Modified-Since'.
+type Cache struct {
+	dir string
+	ttl map[Class]time.Duration
+	// Refresh forces revalidation of all responses, regardless of their age.
+	Refresh bool
+	// Offline serves all responses from the cache, regardless of their age, and never accesses the network.
+	Offline bool
+}
+
+// cacheEntry is the metadata stored alongside each body.
+type cacheEntry struct {
+	URL    string
+	Stored time.Time
+	Header http.Header
+}
+
+func (e cacheEntry) etag() string {
+	return e.Header.Get("ETag")
+}
+
+func (e cacheEntry) lastModified() string {
+	return e.Header.Get("Last-Modified")
+}
+
+// DefaultCacheDir returns the cache directory according to the XDG spec, i.e. '$XDG_CACHE_HOME/arch-log'.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "arch-log"), nil
+}
+
+func NewCache(dir string) *Cache {
+	ttl := make(map[Class]time.Duration, len(defaultTTL))
+	for c, d := range defaultTTL {
+		ttl[c] = d
+	}
+
+	return &Cache{dir: dir, ttl: ttl}
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) SetTTL(class Class, ttl time.Duration) error {
+	if _, ok := c.ttl[class]; !ok {
+		return fmt.Errorf("unknown endpoint class '%s'", class)
+	}
+	c.ttl[class] = ttl
+	return nil
+}
+
+// Clear removes all cached responses. As the directory may hold other files as well (e.g. when being a mirror),
+// only the files of the cache are removed, followed by the directories left empty.
+func (c *Cache) Clear() error {
+	shards, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, shard := range shards {
+		if !shard.IsDir() || !isKey(shard.Name(), 2) {
+			continue
+		}
+
+		dir := filepath.Join(c.dir, shard.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			key := strings.TrimSuffix(strings.TrimSuffix(f.Name(), ".json"), ".body")
+			if f.Type().IsRegular() && key != f.Name() && isKey(key, sha256.Size*2) && strings.HasPrefix(key, shard.Name()) {
+				if err = os.Remove(filepath.Join(dir, f.Name())); err != nil {
+					return err
+				}
+			}
+		}
+
+		// fails if other files are left, which is fine
+		_ = os.Remove(dir)
+	}
+
+	_ = os.Remove(c.dir)
+	return nil
+}
+
+// isKey reports whether s is a (prefix of a) key of the cache, i.e. a hex string of the given length.
+func isKey(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+func (c *Cache) paths(url string) (string, string) {
+	hash := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(hash[:])
+	base := filepath.Join(c.dir, key[:2], key)
+
+	return base + ".json", base + ".body"
+}
+
+func (c *Cache) load(ctx context.Context, url string) (cacheEntry, bool) {
+	metaPath, _ := c.paths(url)
+
+	content, err := os.ReadFile(metaPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.From(ctx).Debugf("Cannot read cache entry for %s: %v", url, err)
+		}
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(content, &entry); err != nil || entry.URL != url {
+		log.From(ctx).Debugf("Ignoring invalid cache entry for %s", url)
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *Cache) open(url string, entry cacheEntry) (Response, error) {
+	_, bodyPath := c.paths(url)
+
+	f, err := os.Open(bodyPath)
+	if err != nil {
+		return Response{}, fmt.Errorf("reading cached %s: %w", url, err)
+	}
+
+	return Response{f, entry.Header}, nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) store(url string, entry cacheEntry, body []byte) error {
+	metaPath, bodyPath := c.paths(url)
+
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		if err = writeFileAtomic(bodyPath, body); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(metaPath, meta)
+}
+
+func (c *Cache) get(ctx context.Context, client *Client, url string, class Class) (Response, error) {
+	entry, cached := c.load(ctx, url)
+
+	if c.Offline {
+		if !cached {
+			return Response{}, fmt.Errorf("%w: %s", ErrNotCached, url)
+		}
+		log.From(ctx).Debugf("Serving %s from cache (offline)", url)
+		return c.open(url, entry)
+	}
+
+	if cached && !c.Refresh && time.Since(entry.Stored) < c.ttl[class] {
+		log.From(ctx).Debugf("Serving %s from cache", url)
+		return c.open(url, entry)
+	}
+
+	header := http.Header{}
+	if cached {
+		if etag := entry.etag(); etag != "" {
+			header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.lastModified(); lastModified != "" {
+			header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := client.do(ctx, url, header)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		log.From(ctx).Debugf("Cached %s not modified", url)
+
+		entry.Stored = time.Now()
+		if err := c.store(url, entry, nil); err != nil {
+			log.From(ctx).Debugf("Cannot update cache entry for %s: %v", url, err)
+		}
+		return c.open(url, entry)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, fmt.Errorf("fetching %s: %w", url, err)
+	}
+
+	entry = cacheEntry{URL: url, Stored: time.Now(), Header: resp.Header}
+	if err := c.store(url, entry, body); err != nil {
+		log.From(ctx).Warnf("Cannot write cache entry for %s: %v", url, err)
+	}
+
+	return Response{io.NopCloser(bytes.NewReader(body)), resp.Header}, nil
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClear(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir)
+
+	urls := []string{"https://example.org/a", "https://example.org/b"}
+	for _, url := range urls {
+		if err := c.store(url, cacheEntry{URL: url, Stored: time.Now()}, []byte("body")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// files not belonging to the cache, e.g. of a mirror
+	metaPath, _ := c.paths(urls[0])
+	foreign := []string{
+		filepath.Join(dir, "README"),
+		filepath.Join(dir, "pool", "foo.pkg.tar.zst"),
+		filepath.Join(filepath.Dir(metaPath), "notes.json"),
+	}
+	for _, f := range foreign {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range urls {
+		metaPath, bodyPath := c.paths(url)
+		for _, p := range []string{metaPath, bodyPath} {
+			if _, err := os.Stat(p); !os.IsNotExist(err) {
+				t.Errorf("'%s' has not been removed", p)
+			}
+		}
+	}
+
+	for _, f := range foreign {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("'%s' has been removed", f)
+		}
+	}
+}
+
+func TestClearMissing(t *testing.T) {
+	c := NewCache(filepath.Join(t.TempDir(), "missing"))
+	if err := c.Clear(); err != nil {
+		t.Errorf("Clear() of a missing directory = %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+const (
+	// initial delay between retries, doubled on each attempt
+	backoffBase = 500 * time.Millisecond
+	// maximum delay between retries, also when requested by 'Retry-After'
+	backoffMax = time.Minute
+)
+
+// Config configures a Client.
+type Config struct {
+	// Timeout of a single request, including reading the body. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of retries on network errors, '429 Too Many Requests', and server errors.
+	Retries int
+	// UserAgent is sent with each request.
+	UserAgent string
+	// Proxy is the URL of the proxy to use. If empty, the proxy is taken from the environment
+	// (HTTP_PROXY, HTTPS_PROXY, NO_PROXY). "direct" disables the use of a proxy.
+	Proxy string
+}
+
+type Client struct {
+	client    *http.Client
+	userAgent string
+	retries   int
+	// Cache, if set, stores responses on disk.
+	Cache *Cache
+}
+
+// Default is used by Get and Fetch, unless the context carries another client (see WithClient).
+var Default = &Client{client: http.DefaultClient}
+
+func NewClient(cfg Config) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch cfg.Proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case "direct":
+		transport.Proxy = nil
+	default:
+		proxyUrl, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy '%s': %w", cfg.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	return &Client{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		},
+		userAgent: cfg.UserAgent,
+		retries:   cfg.Retries,
+	}, nil
+}
+
+// Offline reports whether only cached responses are used.
+func (c *Client) Offline() bool {
+	return c.Cache != nil && c.Cache.Offline
+}
+
+func (c *Client) Get(ctx context.Context, url string, class Class) (Response, error) {
+	if c.Cache != nil {
+		return c.Cache.get(ctx, c, url, class)
+	}
+
+	resp, err := c.do(ctx, url, nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{resp.Body, resp.Header}, nil
+}
+
+func (c *Client) Fetch(ctx context.Context, url string, class Class) (io.ReadCloser, error) {
+	resp, err := c.Get(ctx, url, class)
+	return resp.Body, err
+}
+
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter parses the 'Retry-After' header, which is either in seconds or an HTTP date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
+
+// cap of the doublings of backoffBase: backoffMax is reached long before, while larger shifts overflow
+const backoffMaxShift = 16
+
+func backoff(attempt int, resp *http.Response) time.Duration {
+	delay := backoffBase << min(attempt, backoffMaxShift)
+
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header); ok {
+			delay = d
+		}
+	}
+
+	return max(0, min(delay, backoffMax))
+}
+
+// sleep waits for the given duration, unless the context is cancelled before.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.From(ctx).Debugf("GET %s failed after %v: %v", url, time.Since(start), err)
+		return nil, err
+	}
+
+	log.From(ctx).Debugf("GET %s: %s in %v", url, resp.Status, time.Since(start))
+	return resp, nil
+}
+
+// do performs the request with the given additional headers, retrying on temporary failures.
+// A '304 Not Modified' is not treated as an error.
+func (c *Client) do(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		resp, err = c.doOnce(ctx, url, header)
+
+		retry := (err != nil || isRetryable(resp.StatusCode)) && attempt < c.retries && ctx.Err() == nil
+		if !retry {
+			break
+		}
+
+		delay := backoff(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.From(ctx).Debugf("Retrying %s in %v (retry %d of %d)", url, delay, attempt+1, c.retries)
+		if err = sleep(ctx, delay); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("fetching %s: Server returned status %s", url, resp.Status)
+	}
+
+	return resp, nil
+}
@@ -1,22 +1,73 @@
 package http
 
 import (
-	"fmt"
+	"context"
 	"io"
 	"net/http"
+	"strings"
 )
 
-func Fetch(url string) (io.ReadCloser, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("fetching %s: %w", url, err)
+// Class categorizes endpoints by how often their responses change. It determines how long responses are cached.
+type Class string
+
+const (
+	// Search is used for package searches and lookups.
+	Search Class = "search"
+	// History is used for commit logs and tag lists.
+	History Class = "history"
+	// Content is used for files at moving refs, e.g. the current PKGBUILD.
+	Content Class = "content"
+	// Immutable is used for content addressed by commit or tag, e.g. diffs.
+	Immutable Class = "immutable"
+)
+
+// Response is the body of a successful request together with its headers.
+type Response struct {
+	Body   io.ReadCloser
+	Header http.Header
+}
+
+type clientKey struct{}
+
+// WithClient returns a context making Get and Fetch use the given client instead of Default.
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFrom returns the client of the context, falling back to Default.
+func ClientFrom(ctx context.Context) *Client {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return c
 	}
+	return Default
+}
+
+func Get(ctx context.Context, url string, class Class) (Response, error) {
+	return ClientFrom(ctx).Get(ctx, url, class)
+}
+
+func Fetch(ctx context.Context, url string, class Class) (io.ReadCloser, error) {
+	return ClientFrom(ctx).Fetch(ctx, url, class)
+}
 
-	if resp.StatusCode >= 300 {
-		resp.Body.Close()
+// NextLink returns the URL marked as rel="next" in the 'Link' header, or the empty string.
+func NextLink(header http.Header) string {
+	for _, link := range header.Values("Link") {
+		for _, part := range strings.Split(link, ",") {
+			target, params, found := strings.Cut(part, ";")
+			if !found {
+				continue
+			}
 
-		return nil, fmt.Errorf("fetching %s: Server returned status %s", url, resp.Status)
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if key == "rel" && strings.Trim(value, `"`) == "next" {
+					target = strings.TrimSpace(target)
+					return strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+				}
+			}
+		}
 	}
 
-	return resp.Body, nil
+	return ""
 }
@@ -1,15 +1,19 @@
 package log
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 )
 
 var debugLogger = log.New(os.Stderr, "DEBUG: ", 0)
 var verboseLogger = log.New(os.Stderr, " INFO: ", 0)
 var errorLogger = log.New(os.Stderr, "ERROR: ", 0)
-var warnLogger = log.New(os.Stdout, " WARN: ", 0)
+var warnLogger = log.New(os.Stderr, " WARN: ", 0)
 
 type logLevel byte
 
@@ -75,3 +79,110 @@ func Warn(v ...any) {
 func Warnf(format string, a ...any) {
 	_ = warnLogger.Output(2, fmt.Sprintf(format, a...))
 }
+
+// Logger receives the messages emitted while serving a request, e.g. by the providers and the HTTP layer.
+type Logger interface {
+	Debugf(format string, v ...any)
+	Printf(format string, v ...any)
+	Warnf(format string, v ...any)
+}
+
+// global logs using the package-level functions, honoring the level set.
+type global struct{}
+
+func (global) Debugf(format string, v ...any) {
+	if level <= debug {
+		_ = debugLogger.Output(3, fmt.Sprintf(format, v...))
+	}
+}
+
+func (global) Printf(format string, v ...any) {
+	if level <= info {
+		_ = verboseLogger.Output(3, fmt.Sprintf(format, v...))
+	}
+}
+
+func (global) Warnf(format string, v ...any) {
+	_ = warnLogger.Output(3, fmt.Sprintf(format, v...))
+}
+
+// Default logs using the package-level functions, honoring the level set.
+var Default Logger = global{}
+
+type discard struct{}
+
+func (discard) Debugf(string, ...any) {}
+func (discard) Printf(string, ...any) {}
+func (discard) Warnf(string, ...any)  {}
+
+// Discard drops all messages.
+var Discard Logger = discard{}
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose messages go to the given logger instead of the package-level functions.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// WithDefault is WithLogger, unless the context carries a logger already.
+func WithDefault(ctx context.Context, l Logger) context.Context {
+	if _, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return ctx
+	}
+	return WithLogger(ctx, l)
+}
+
+// Buffer is a Logger collecting the messages, honoring the level set, until they are flushed to stderr.
+// This keeps the messages of concurrent jobs together.
+type Buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *Buffer) add(prefix, format string, v []any) {
+	msg := fmt.Sprintf(format, v...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf.WriteString(prefix)
+	b.buf.WriteString(msg)
+	if !strings.HasSuffix(msg, "\n") {
+		b.buf.WriteByte('\n')
+	}
+}
+
+func (b *Buffer) Debugf(format string, v ...any) {
+	if level <= debug {
+		b.add(debugLogger.Prefix(), format, v)
+	}
+}
+
+func (b *Buffer) Printf(format string, v ...any) {
+	if level <= info {
+		b.add(verboseLogger.Prefix(), format, v)
+	}
+}
+
+func (b *Buffer) Warnf(format string, v ...any) {
+	b.add(warnLogger.Prefix(), format, v)
+}
+
+// Flush writes the messages collected so far to stderr.
+func (b *Buffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := os.Stderr.Write(b.buf.Bytes())
+	b.buf.Reset()
+	return err
+}
+
+// From returns the logger of the context, falling back to the package-level functions.
+func From(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return l
+	}
+	return Default
+}
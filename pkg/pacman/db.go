@@ -0,0 +1,272 @@
+package pacman
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DB describes the location of pacman's databases.
+type DB struct {
+	// Root is the installation root, usually '/'.
+	Root string
+	// Path is the database path, usually '/var/lib/pacman'. If empty, it is derived from Root.
+	Path string
+}
+
+func (db DB) path() string {
+	if db.Path != "" {
+		return db.Path
+	}
+	return filepath.Join(db.Root, "var/lib/pacman")
+}
+
+func (db DB) configPath() string {
+	return filepath.Join(db.Root, "etc/pacman.conf")
+}
+
+// Local returns all installed packages.
+func (db DB) Local() ([]Package, error) {
+	localPath := filepath.Join(db.path(), "local")
+
+	dirs, err := os.ReadDir(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading local database: %w", err)
+	}
+
+	var pkgs []Package
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		pkg, err := readDesc(filepath.Join(localPath, d.Name(), "desc"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, nil
+}
+
+func readDesc(file string) (Package, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Package{}, err
+	}
+	defer f.Close()
+
+	pkg, err := parseDesc(f)
+	if err != nil {
+		return Package{}, fmt.Errorf("reading '%s': %w", file, err)
+	}
+	return pkg, nil
+}
+
+// Repos returns the names of the sync databases. They are in the order of pacman.conf, if it can be read,
+// and sorted alphabetically otherwise.
+func (db DB) Repos() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(db.path(), "sync", "*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]bool, len(files))
+	for _, f := range files {
+		available[strings.TrimSuffix(filepath.Base(f), ".db")] = true
+	}
+
+	var repos []string
+	if order, err := readRepoOrder(db.configPath()); err == nil {
+		for _, r := range order {
+			if available[r] {
+				repos = append(repos, r)
+				delete(available, r)
+			}
+		}
+	}
+
+	var rest []string
+	for r := range available {
+		rest = append(rest, r)
+	}
+	sort.Strings(rest)
+
+	return append(repos, rest...), nil
+}
+
+// readRepoOrder returns the repository sections of pacman.conf in order.
+func readRepoOrder(config string) ([]string, error) {
+	f, err := os.Open(config)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var repos []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if section := line[1 : len(line)-1]; section != "options" {
+				repos = append(repos, section)
+			}
+		}
+	}
+
+	return repos, scanner.Err()
+}
+
+// syncDB is a parsed sync database, valid as long as the file is not changed.
+type syncDB struct {
+	modTime time.Time
+	size    int64
+	load    func() ([]Package, error)
+}
+
+// parsed sync databases by file, so that each is only read once per process (unless it changes)
+var syncDBs = struct {
+	sync.Mutex
+	m map[string]*syncDB
+}{m: make(map[string]*syncDB)}
+
+// Sync returns all packages of the given sync database. The result is shared and must not be modified.
+func (db DB) Sync(repo string) ([]Package, error) {
+	file := filepath.Join(db.path(), "sync", repo+".db")
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading sync database: %w", err)
+	}
+
+	syncDBs.Lock()
+	entry, ok := syncDBs.m[file]
+	if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		entry = &syncDB{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			load: sync.OnceValues(func() ([]Package, error) {
+				return readSyncFile(file, repo)
+			}),
+		}
+		syncDBs.m[file] = entry
+	}
+	syncDBs.Unlock()
+
+	return entry.load()
+}
+
+func readSyncFile(file, repo string) ([]Package, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading sync database: %w", err)
+	}
+	defer f.Close()
+
+	pkgs, err := readSyncDB(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading sync database '%s': %w", file, err)
+	}
+
+	for i := range pkgs {
+		pkgs[i].Repo = repo
+	}
+	return pkgs, nil
+}
+
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, errors.New("zstd compression is not supported")
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X'}):
+		return nil, errors.New("xz compression is not supported")
+	default:
+		return br, nil
+	}
+}
+
+func readSyncDB(r io.Reader) ([]Package, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []Package
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != "desc" {
+			continue
+		}
+
+		pkg, err := parseDesc(tr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing '%s': %w", hdr.Name, err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, nil
+}
+
+// Lookup returns the package from each sync database containing it, in the order of Repos.
+// Only the databases of the repos accepted are read, nil accepts all.
+func (db DB) Lookup(name string, accept func(repo string) bool) ([]Package, error) {
+	repos, err := db.Repos()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Package
+	for _, repo := range repos {
+		if accept != nil && !accept(repo) {
+			continue
+		}
+
+		pkgs, err := db.Sync(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pkgs {
+			if p.Name == name {
+				found = append(found, p)
+				break
+			}
+		}
+	}
+
+	return found, nil
+}
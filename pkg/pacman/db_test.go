@@ -0,0 +1,165 @@
+package pacman
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+// fixture is a pacman root with the sync databases core, extra, and custom (in this order in pacman.conf)
+// and a handful of installed packages. The database of custom is zstd compressed, which is not supported.
+var fixture = DB{Root: "testdata/root"}
+
+func TestParseDesc(t *testing.T) {
+	tests := []struct {
+		name string
+		desc string
+		want Package
+	}{
+		{
+			"split package",
+			"%NAME%\npython-foo\n\n%BASE%\nfoo\n\n%VERSION%\n1:1.0-1\n\n%DESC%\nSome text\n",
+			Package{Name: "python-foo", Base: "foo", Version: "1:1.0-1"},
+		},
+		{
+			"base defaults to name",
+			"%FILENAME%\nlinux-6.10.1-1-x86_64.pkg.tar.zst\n\n%NAME%\nlinux\n\n%VERSION%\n6.10.1-1\n",
+			Package{Name: "linux", Base: "linux", Version: "6.10.1-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDesc(strings.NewReader(tt.desc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("parseDesc() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRepoOrder(t *testing.T) {
+	got, err := readRepoOrder(fixture.configPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"core", "extra", "custom"}
+	if !slices.Equal(got, want) {
+		t.Errorf("readRepoOrder() = %v, want %v", got, want)
+	}
+}
+
+func TestRepos(t *testing.T) {
+	got, err := fixture.Repos()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"core", "extra", "custom"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Repos() = %v, want %v", got, want)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	pkgs, err := fixture.Local()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range pkgs {
+		names = append(names, p.Name)
+	}
+	slices.Sort(names)
+
+	want := []string{"firefox", "glibc", "linux", "mypkg", "python-foo"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Local() = %v, want %v", names, want)
+	}
+}
+
+func TestSync(t *testing.T) {
+	pkgs, err := fixture.Sync("extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Package{
+		{Name: "linux", Base: "linux", Version: "6.11.0-1", Repo: "extra"},
+		{Name: "firefox", Base: "firefox", Version: "130.0-1", Repo: "extra"},
+		{Name: "python-foo", Base: "foo", Version: "1:1.0-1", Repo: "extra"},
+	}
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("Sync() = %+v, want %+v", pkgs, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	pkgs, err := fixture.Lookup("linux", func(repo string) bool {
+		return repo != "custom"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Package{
+		{Name: "linux", Base: "linux", Version: "6.10.1-1", Repo: "core"},
+		{Name: "linux", Base: "linux", Version: "6.11.0-1", Repo: "extra"},
+	}
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("Lookup() = %+v, want %+v", pkgs, want)
+	}
+}
+
+func TestUpgrades(t *testing.T) {
+	// the unreadable custom repo is skipped
+	got, err := fixture.Upgrades(log.WithLogger(context.Background(), log.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Upgrade{
+		{
+			// core precedes extra, which has an even newer version
+			Installed: Package{Name: "linux", Base: "linux", Version: "6.10.0-1"},
+			Available: Package{Name: "linux", Base: "linux", Version: "6.10.1-1", Repo: "core"},
+		},
+		{
+			// the epoch wins over the lower pkgver
+			Installed: Package{Name: "python-foo", Base: "foo", Version: "2.0-1"},
+			Available: Package{Name: "python-foo", Base: "foo", Version: "1:1.0-1", Repo: "extra"},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Upgrades() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLookupUnreadable(t *testing.T) {
+	if _, err := fixture.Lookup("linux", nil); err == nil {
+		t.Error("Lookup() of the zstd compressed custom repo succeeded, want an error")
+	}
+}
+
+func TestLookupAccept(t *testing.T) {
+	pkgs, err := fixture.Lookup("linux", func(repo string) bool {
+		return repo == "extra"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkgs) != 1 || pkgs[0].Repo != "extra" {
+		t.Errorf("Lookup() = %+v, want only the package from extra", pkgs)
+	}
+}
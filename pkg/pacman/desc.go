@@ -0,0 +1,49 @@
+// Package pacman reads the local and sync databases of pacman.
+package pacman
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+type Package struct {
+	Name    string
+	Base    string
+	Version string
+	// Repo is the name of the sync database, empty for installed packages.
+	Repo string
+}
+
+// parseDesc parses a 'desc' file of a database entry.
+func parseDesc(r io.Reader) (Package, error) {
+	var pkg Package
+	var section string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			section = ""
+		case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			section = line
+		default:
+			switch section {
+			case "%NAME%":
+				pkg.Name = line
+			case "%BASE%":
+				pkg.Base = line
+			case "%VERSION%":
+				pkg.Version = line
+			}
+		}
+	}
+
+	if pkg.Base == "" {
+		pkg.Base = pkg.Name
+	}
+
+	return pkg, scanner.Err()
+}
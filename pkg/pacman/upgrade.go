@@ -0,0 +1,57 @@
+package pacman
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/version"
+)
+
+type Upgrade struct {
+	Installed Package
+	Available Package
+}
+
+// Upgrades returns the installed packages for which a newer version is available in the sync databases.
+// As pacman does, only the first sync database containing a package is considered.
+// Sync databases that cannot be read (e.g. due to an unsupported compression) are skipped with a warning.
+func (db DB) Upgrades(ctx context.Context) ([]Upgrade, error) {
+	local, err := db.Local()
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := db.Repos()
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]Package)
+	for _, repo := range repos {
+		pkgs, err := db.Sync(repo)
+		if err != nil {
+			log.From(ctx).Warnf("Skipping repo '%s': %v", repo, err)
+			continue
+		}
+
+		for _, p := range pkgs {
+			if _, ok := available[p.Name]; !ok {
+				available[p.Name] = p
+			}
+		}
+	}
+
+	var upgrades []Upgrade
+	for _, installed := range local {
+		if avail, ok := available[installed.Name]; ok && version.Compare(avail.Version, installed.Version) > 0 {
+			upgrades = append(upgrades, Upgrade{installed, avail})
+		}
+	}
+
+	sort.Slice(upgrades, func(i, j int) bool {
+		return upgrades[i].Installed.Name < upgrades[j].Installed.Name
+	})
+
+	return upgrades, nil
+}
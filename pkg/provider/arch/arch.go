@@ -1,15 +1,20 @@
 package arch
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	gohttp "net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Necoro/arch-log/pkg/entries"
 	"github.com/Necoro/arch-log/pkg/http"
 	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/provider"
+	"github.com/Necoro/arch-log/pkg/version"
 )
 
 type commit struct {
@@ -20,17 +25,49 @@ type commit struct {
 	Id        string
 }
 
+type fileDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string
+}
+
+func (d fileDiff) unified() string {
+	oldPath := "a/" + d.OldPath
+	newPath := "b/" + d.NewPath
+
+	sb := strings.Builder{}
+	sb.WriteString("diff --git " + oldPath + " " + newPath + "\n")
+
+	if d.NewFile {
+		oldPath = "/dev/null"
+	}
+	if d.DeletedFile {
+		newPath = "/dev/null"
+	}
+	sb.WriteString("--- " + oldPath + "\n")
+	sb.WriteString("+++ " + newPath + "\n")
+
+	sb.WriteString(d.Diff)
+	if !strings.HasSuffix(d.Diff, "\n") {
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
 type tag struct {
 	Name   string
 	Commit struct{ Id string }
 }
 
-func (e commit) convertTime() time.Time {
+func (e commit) convertTime(logger log.Logger) time.Time {
 	if e.Timestamp == "" {
 		return time.Time{}
 	}
 	if t, err := time.Parse(time.RFC3339, e.Timestamp); err != nil {
-		log.Warnf("Problem parsing time '%s' -- ignoring: %v.", e.Timestamp, err)
+		logger.Warnf("Problem parsing time '%s' -- ignoring: %v.", e.Timestamp, err)
 		return time.Time{}
 	} else {
 		return t
@@ -49,28 +86,74 @@ func (e commit) cleanedMessage() string {
 	return e.Message[headerEnd+1:]
 }
 
-func fetch(url string, jsonEntries any) error {
-	result, err := http.Fetch(url)
+// maximum page size supported by GitLab
+const maxPerPage = 100
+
+// fetchPage fetches one page of a paginated listing and returns the url of the next page,
+// which is empty for the last page.
+func fetchPage(ctx context.Context, url string, class http.Class, jsonEntries any) (string, error) {
+	result, err := http.Get(ctx, url, class)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer result.Close()
+	defer result.Body.Close()
 
-	log.Debugf("Fetching from Arch (%s) successful.", url)
+	log.From(ctx).Debugf("Fetching from Arch (%s) successful.", url)
 
-	d := json.NewDecoder(result)
+	d := json.NewDecoder(result.Body)
 	if err := d.Decode(jsonEntries); err != nil {
-		return err
+		return "", err
+	}
+
+	return nextPageUrl(ctx, url, result.Header), nil
+}
+
+func nextPageUrl(ctx context.Context, pageUrl string, header gohttp.Header) string {
+	if next := http.NextLink(header); next != "" {
+		return next
+	}
+
+	nextPage := header.Get("X-Next-Page")
+	if nextPage == "" {
+		return ""
 	}
-	return nil
+
+	u, err := url.Parse(pageUrl)
+	if err != nil {
+		log.From(ctx).Warnf("Cannot parse url '%s' -- not fetching further pages: %v", pageUrl, err)
+		return ""
+	}
+
+	query := u.Query()
+	query.Set("page", nextPage)
+	u.RawQuery = query.Encode()
+
+	return u.String()
 }
 
-func buildCommitsUrl(pkg string) string {
-	return buildUrl(pkg, "commits")
+// buildCommitsUrl returns the url of the commits listing, restricted to the given time range.
+// The bounds are widened to whole days, so that cached pages can be reused for some time (the exact
+// range is applied by the window).
+func buildCommitsUrl(pkg string, since, until time.Time) string {
+	// always use the same page size, so that cached pages can be reused regardless of the limit
+	action := "commits?per_page=" + strconv.Itoa(maxPerPage)
+
+	if !since.IsZero() {
+		action += "&since=" + url.QueryEscape(since.UTC().Truncate(24*time.Hour).Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		action += "&until=" + url.QueryEscape(until.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1).Format(time.RFC3339))
+	}
+
+	return buildUrl(pkg, action)
 }
 
 func buildTagsUrl(pkg string) string {
-	return buildUrl(pkg, "tags")
+	return buildUrl(pkg, "tags?per_page="+strconv.Itoa(maxPerPage))
+}
+
+func buildDiffUrl(pkg, commitId string) string {
+	return buildUrl(pkg, "commits/"+url.PathEscape(commitId)+"/diff?per_page="+strconv.Itoa(maxPerPage))
 }
 
 func buildPkgBuildUrl(pkg, ref string) string {
@@ -85,6 +168,32 @@ func buildUrl(pkg, action string) string {
 	return "https://gitlab.archlinux.org/api/v4/projects/" + repoName + "/repository/" + action
 }
 
+// CommitURL returns the URL of the commit's page in the GitLab web interface.
+func CommitURL(basePkg, commitId string) string {
+	return "https://gitlab.archlinux.org/archlinux/packaging/packages/" + url.PathEscape(basePkg) + "/-/commit/" + url.PathEscape(commitId)
+}
+
+func fetchTags(ctx context.Context, basePkg string) ([]tag, error) {
+	var tags []tag
+
+	url := buildTagsUrl(basePkg)
+	for url != "" {
+		var page []tag
+		var err error
+		if url, err = fetchPage(ctx, url, http.History, &page); err != nil {
+			return nil, err
+		}
+		tags = append(tags, page...)
+	}
+
+	return tags, nil
+}
+
+type tagsResult struct {
+	tags []tag
+	err  error
+}
+
 func groupTag(tags []tag) map[string]string {
 	m := make(map[string]string, len(tags))
 	for _, t := range tags {
@@ -94,81 +203,174 @@ func groupTag(tags []tag) map[string]string {
 	return m
 }
 
-func convert(commits []commit, tags []tag, repoInfo repoInfo) []entries.Change {
-	changeList := make([]entries.Change, 0, len(commits))
-	tagMap := groupTag(tags)
-	constrain := repoInfo.isRestricted()
-	constrainRepo := repoInfo.repoConstraint()
-	printRepo := !constrain
+type converter struct {
+	logger        log.Logger
+	window        *provider.Window
+	tagMap        map[string]string
+	repoInfo      repoInfo
+	constrain     bool
+	constrainRepo string
+	changes       []entries.Change
+}
+
+func newConverter(ctx context.Context, tags []tag, repoInfo repoInfo, window *provider.Window) *converter {
+	conv := &converter{
+		logger:        log.From(ctx),
+		window:        window,
+		tagMap:        groupTag(tags),
+		repoInfo:      repoInfo,
+		constrain:     repoInfo.isRestricted(),
+		constrainRepo: repoInfo.repoConstraint(),
+	}
 
-	if constrain {
-		log.Printf("Restricting commits to repo '%s'", constrainRepo)
+	if conv.constrain {
+		conv.logger.Printf("Restricting commits to repo '%s'", conv.constrainRepo)
 	}
 
+	return conv
+}
+
+// convert adds the given commits, which are expected to be ordered from newest to oldest.
+func (conv *converter) convert(commits []commit) {
+	printRepo := !conv.repoInfo.isRestricted()
+
 	for _, c := range commits {
-		log.Debugf("Fetched commit %+v", c)
+		conv.logger.Debugf("Fetched commit %+v", c)
 
-		tag := tagMap[c.Id]
-		repo := repoInfo[tag]
+		tag := conv.tagMap[c.Id]
+		repo := conv.repoInfo[tag]
 
-		if !constrain || constrainRepo == repo {
-			constrain = false
+		if !conv.constrain || conv.constrainRepo == repo {
+			conv.constrain = false
 
 			c := entries.Change{
-				CommitTime: c.convertTime(),
+				Id:         c.Id,
+				CommitTime: c.convertTime(conv.logger),
 				Author:     c.Author,
 				Summary:    c.Title,
 				Message:    c.cleanedMessage(),
-				Tag:        tagMap[c.Id]}
+				Tag:        tag}
 
 			if printRepo {
 				c.RepoInfo = repo
 			}
 
-			changeList = append(changeList, c)
+			if conv.window.Accept(c) {
+				conv.changes = append(conv.changes, c)
+			}
 		}
 	}
-	return changeList
 }
 
+// GetEntries returns at least q.Limit changes (if there are as many), fetching only the pages needed.
+// A limit of 0 fetches all commits.
+//
 //goland:noinspection GoImportUsedAsName
-func GetEntries(pkg, repo string) ([]entries.Change, error) {
-	basePkg, repoInfo, err := determineBaseInfo(pkg, repo)
+func GetEntries(ctx context.Context, q provider.Query) (entries.Log, error) {
+	limit := q.Limit
+
+	info, repoInfo, err := determineBaseInfo(ctx, q.Pkg, q.Repo)
 	if err != nil {
-		return nil, err
+		return entries.Log{}, err
 	}
+	basePkg := info.PkgBase
+
+	// stops fetching the tags, if fetching the commits fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tagsChan := make(chan tagsResult, 1)
+	go func() {
+		tags, err := fetchTags(ctx, basePkg)
+		tagsChan <- tagsResult{tags, err}
+	}()
 
-	url := buildCommitsUrl(basePkg)
+	// the first page is fetched while the tags are still loading
 	var commits []commit
-	if err := fetch(url, &commits); err != nil {
-		return nil, err
+	since, until := q.Since, q.Until
+	if http.ClientFrom(ctx).Offline() {
+		// only the unrestricted listing is prefetched
+		since, until = time.Time{}, time.Time{}
 	}
 
-	url = buildTagsUrl(basePkg)
-	var tags []tag
-	if err := fetch(url, &tags); err != nil {
-		return nil, err
+	url := buildCommitsUrl(basePkg, since, until)
+	if url, err = fetchPage(ctx, url, http.History, &commits); err != nil {
+		return entries.Log{}, err
 	}
 
-	return convert(commits, tags, repoInfo), nil
+	tags := <-tagsChan
+	if tags.err != nil {
+		return entries.Log{}, tags.err
+	}
+
+	conv := newConverter(ctx, tags.tags, repoInfo, provider.NewWindow(q))
+	conv.convert(commits)
+
+	for url != "" && !conv.window.Done() && (limit <= 0 || len(conv.changes) < limit) {
+		var commits []commit
+		if url, err = fetchPage(ctx, url, http.History, &commits); err != nil {
+			return entries.Log{}, err
+		}
+		conv.convert(commits)
+	}
+
+	return entries.Log{
+		PkgBase: basePkg,
+		Repo:    resolvedRepo(info, repoInfo),
+		Changes: conv.changes,
+	}, nil
+}
+
+// GetPatch returns the changes of the given commit as unified diff.
+func GetPatch(ctx context.Context, basePkg, commitId string) (string, error) {
+	sb := strings.Builder{}
+
+	url := buildDiffUrl(basePkg, commitId)
+	for url != "" {
+		var diffs []fileDiff
+		var err error
+		if url, err = fetchPage(ctx, url, http.Immutable, &diffs); err != nil {
+			return "", err
+		}
+
+		for _, d := range diffs {
+			sb.WriteString(d.unified())
+		}
+	}
+
+	return sb.String(), nil
 }
 
-func GetPkgBuild(pkg, repo string) (io.ReadCloser, error) {
-	basePkg, repoInfo, err := determineBaseInfo(pkg, repo)
+// GetPkgBuild returns the PKGBUILD at the given ref, which is either a version, a commit, or a repo.
+// An empty ref denotes the current version in the given repo.
+func GetPkgBuild(ctx context.Context, pkg, repo, ref string) (io.ReadCloser, error) {
+	if provider.IsRepoRef(ref) {
+		repo = ref
+		ref = ""
+	}
+
+	info, repoInfo, err := determineBaseInfo(ctx, pkg, repo)
 	if err != nil {
 		return nil, err
 	}
 
 	commitRef := repoInfo.refConstraint()
+	if ref != "" {
+		commitRef = version.ToTag(ref)
+	}
+
+	class := http.Immutable
+	if commitRef == "HEAD" {
+		class = http.Content
+	}
 
-	url := buildPkgBuildUrl(basePkg, commitRef)
-	body, err := http.Fetch(url)
+	url := buildPkgBuildUrl(info.PkgBase, commitRef)
+	body, err := http.Fetch(ctx, url, class)
 	if err != nil {
-		body.Close()
 		return nil, err
 	}
 
-	log.Debugf("Fetching from Arch (%s) successful.", url)
+	log.From(ctx).Debugf("Fetching from Arch (%s) successful.", url)
 
 	return body, nil
 }
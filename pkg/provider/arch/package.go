@@ -1,6 +1,7 @@
 package arch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -9,18 +10,21 @@ import (
 	"github.com/Necoro/arch-log/pkg/entries"
 	"github.com/Necoro/arch-log/pkg/http"
 	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/version"
 )
 
 type result struct {
 	PkgName string
 	PkgBase string
 	Repo    string
+	Epoch   int
 	PkgVer  string
 	PkgRel  string
 }
 
+// tagName returns the git tag of the version.
 func (r result) tagName() string {
-	return r.PkgVer + "-" + r.PkgRel
+	return version.FromParts(r.Epoch, r.PkgVer, r.PkgRel).Tag()
 }
 
 type infos struct {
@@ -58,34 +62,39 @@ func buildPkgUrl(pkg string) string {
 	return "https://archlinux.org/packages/search/json/?name=" + url.QueryEscape(pkg)
 }
 
-func fetchPkgInfo(url, repo string) (result, repoInfo, error) {
-	res, err := http.Fetch(url)
+func fetchPkgInfo(ctx context.Context, url string) ([]result, error) {
+	res, err := http.Fetch(ctx, url, http.Search)
 	if err != nil {
-		return result{}, nil, err
+		return nil, err
 	}
 	defer res.Close()
 
-	log.Debugf("Fetching from Arch PkgInfo (%s) successful.", url)
+	log.From(ctx).Debugf("Fetching from Arch PkgInfo (%s) successful.", url)
 
 	var infos infos
 	d := json.NewDecoder(res)
 	if err = d.Decode(&infos); err != nil {
-		return result{}, nil, err
+		return nil, err
 	}
 
+	return infos.Results, nil
+}
+
+func evaluateResults(results []result, repo string) (result, repoInfo, error) {
 	var repoInfo repoInfo
-	if len(infos.Results) == 0 {
+	if len(results) == 0 {
 		return result{}, repoInfo, entries.ErrNotFound
 	}
 
-	r := infos.Results[0]
-	if len(infos.Results) == 1 && repo != "" && r.Repo != repo {
-		return result{}, nil, fmt.Errorf("package '%s' only found in repo '%s', but '%s' has been requested",
-			r.PkgName, r.Repo, repo)
+	r := results[0]
+	if len(results) == 1 && repo != "" && r.Repo != repo {
+		return result{}, nil, fmt.Errorf("%w: package '%s' only found in repo '%s', but '%s' has been requested",
+			entries.ErrNotInRepo, r.PkgName, r.Repo, repo)
 	}
 
-	if len(infos.Results) > 1 {
-		repoInfo, err = buildRepoInfo(repo, infos.Results)
+	if len(results) > 1 {
+		var err error
+		repoInfo, err = buildRepoInfo(repo, results)
 		if err != nil {
 			return result{}, nil, err
 		}
@@ -94,6 +103,15 @@ func fetchPkgInfo(url, repo string) (result, repoInfo, error) {
 	return r, repoInfo, nil
 }
 
+func containsRepo(results []result, repo string) bool {
+	for _, r := range results {
+		if r.Repo == repo {
+			return true
+		}
+	}
+	return false
+}
+
 func reposString(results []result) string {
 	sb := strings.Builder{}
 
@@ -127,26 +145,44 @@ func buildRepoInfo(repo string, results []result) (repoInfo, error) {
 
 		if !found {
 			repos := reposString(results)
-			return nil, fmt.Errorf("package '%s' only found in repos %s, but '%s' has been requested",
-				results[0].PkgName, repos, repo)
+			return nil, fmt.Errorf("%w: package '%s' only found in repos %s, but '%s' has been requested",
+				entries.ErrNotInRepo, results[0].PkgName, repos, repo)
 		}
 	}
 	return repoInfo, nil
 }
 
-func determineBaseInfo(pkg, repo string) (string, repoInfo, error) {
-	url := buildPkgUrl(pkg)
-	result, repoInfo, err := fetchPkgInfo(url, repo)
+func determineBaseInfo(ctx context.Context, pkg, repo string) (result, repoInfo, error) {
+	results, err := lookupSyncDB(ctx, pkg)
+	if err != nil {
+		log.From(ctx).Debugf("Cannot use sync databases, falling back to web search: %v", err)
+	}
+
+	if len(results) == 0 || (repo != "" && !containsRepo(results, repo)) {
+		results, err = fetchPkgInfo(ctx, buildPkgUrl(pkg))
+		if err != nil {
+			return result{}, nil, err
+		}
+	}
 
+	result, repoInfo, err := evaluateResults(results, repo)
 	if err != nil {
-		return "", nil, err
+		return result, nil, err
 	}
 
-	log.Debugf("Pkg Info from Arch: %+v", result)
+	log.From(ctx).Debugf("Pkg Info from Arch: %+v", result)
 
 	if result.PkgBase != pkg {
-		log.Printf("Mapped pkg '%s' to pkgbase '%s'", pkg, result.PkgBase)
+		log.From(ctx).Printf("Mapped pkg '%s' to pkgbase '%s'", pkg, result.PkgBase)
 	}
 
-	return result.PkgBase, repoInfo, nil
+	return result, repoInfo, nil
+}
+
+// resolvedRepo returns the repo the package is restricted to, or the empty string if it is found in multiple repos.
+func resolvedRepo(result result, repoInfo repoInfo) string {
+	if len(repoInfo) == 0 {
+		return result.Repo
+	}
+	return repoInfo.repoConstraint()
 }
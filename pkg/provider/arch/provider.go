@@ -0,0 +1,44 @@
+package arch
+
+import (
+	"context"
+	"io"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+type archProvider struct{}
+
+func init() {
+	provider.Register(10, archProvider{})
+}
+
+func (archProvider) Name() string {
+	return "Arch"
+}
+
+func (archProvider) Capabilities() provider.Capability {
+	return provider.Repos | provider.Tags | provider.Patches
+}
+
+func (archProvider) ResolveBase(ctx context.Context, pkg, repo string) (string, error) {
+	info, _, err := determineBaseInfo(ctx, pkg, repo)
+	return info.PkgBase, err
+}
+
+func (archProvider) GetEntries(ctx context.Context, q provider.Query) (entries.Log, error) {
+	return GetEntries(ctx, q)
+}
+
+func (archProvider) GetPkgBuild(ctx context.Context, pkg, repo, ref string) (io.ReadCloser, error) {
+	return GetPkgBuild(ctx, pkg, repo, ref)
+}
+
+func (archProvider) GetPatch(ctx context.Context, basePkg, commitId string) (string, error) {
+	return GetPatch(ctx, basePkg, commitId)
+}
+
+func (archProvider) CommitURL(basePkg, commitId string) string {
+	return CommitURL(basePkg, commitId)
+}
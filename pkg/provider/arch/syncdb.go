@@ -0,0 +1,79 @@
+package arch
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/pacman"
+	"github.com/Necoro/arch-log/pkg/version"
+)
+
+type dbKey struct{}
+
+// WithDB returns a context making the provider resolve packages with the given pacman database
+// before querying archlinux.org. Without, the database of the running system is used.
+func WithDB(ctx context.Context, db pacman.DB) context.Context {
+	return context.WithValue(ctx, dbKey{}, db)
+}
+
+func dbFrom(ctx context.Context) pacman.DB {
+	if db, ok := ctx.Value(dbKey{}).(pacman.DB); ok {
+		return db
+	}
+	return pacman.DB{Root: "/"}
+}
+
+// repositories hosted on gitlab.archlinux.org -- others (e.g. custom ones) are ignored
+var officialRepos = map[string]bool{
+	"core":             true,
+	"core-testing":     true,
+	"core-staging":     true,
+	"extra":            true,
+	"extra-testing":    true,
+	"extra-staging":    true,
+	"multilib":         true,
+	"multilib-testing": true,
+	"multilib-staging": true,
+	"gnome-unstable":   true,
+	"kde-unstable":     true,
+}
+
+// IsOfficialRepo returns whether the repository is one of the official ones hosted on gitlab.archlinux.org.
+func IsOfficialRepo(repo string) bool {
+	return officialRepos[repo]
+}
+
+func toResult(p pacman.Package) result {
+	v := version.Parse(p.Version)
+	// Parse only accepts numeric epochs
+	epoch, _ := strconv.Atoi(v.Epoch)
+
+	return result{
+		PkgName: p.Name,
+		PkgBase: p.Base,
+		Repo:    p.Repo,
+		Epoch:   epoch,
+		PkgVer:  v.PkgVer,
+		PkgRel:  v.PkgRel,
+	}
+}
+
+// lookupSyncDB returns the package in all official sync databases containing it.
+func lookupSyncDB(ctx context.Context, pkg string) ([]result, error) {
+	pkgs, err := dbFrom(ctx).Lookup(pkg, func(repo string) bool {
+		return officialRepos[repo]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []result
+	for _, p := range pkgs {
+		results = append(results, toResult(p))
+	}
+
+	log.From(ctx).Debugf("Found '%s' in sync databases: %+v", pkg, results)
+
+	return results, nil
+}
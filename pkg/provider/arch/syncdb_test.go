@@ -0,0 +1,101 @@
+package arch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/pacman"
+)
+
+// testContext resolves with the fixture of the pacman package. The HTTP cache is empty and offline,
+// so that any fallback to the web fails with http.ErrNotCached.
+func testContext(t *testing.T) context.Context {
+	cache := http.NewCache(t.TempDir())
+	cache.Offline = true
+
+	ctx := WithDB(context.Background(), pacman.DB{Root: "../../pacman/testdata/root"})
+	ctx = log.WithLogger(ctx, log.Discard)
+	return http.WithClient(ctx, &http.Client{Cache: cache})
+}
+
+func TestLookupSyncDB(t *testing.T) {
+	results, err := lookupSyncDB(testContext(t), "linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []result{
+		{PkgName: "linux", PkgBase: "linux", Repo: "core", PkgVer: "6.10.1", PkgRel: "1"},
+		{PkgName: "linux", PkgBase: "linux", Repo: "extra", PkgVer: "6.11.0", PkgRel: "1"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("lookupSyncDB() = %+v, want %+v", results, want)
+	}
+}
+
+func TestDetermineBaseInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkg      string
+		repo     string
+		base     string
+		repoInfo repoInfo
+	}{
+		{"split package", "python-foo", "", "foo", nil},
+		{"split package in repo", "python-foo", "extra", "foo", nil},
+		{"multiple repos", "linux", "", "linux", repoInfo{"6.10.1-1": "core", "6.11.0-1": "extra"}},
+		{"restricted to repo", "linux", "extra", "linux", repoInfo{"6.11.0-1": "extra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, info, err := determineBaseInfo(testContext(t), tt.pkg, tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.PkgBase != tt.base {
+				t.Errorf("pkgbase = %q, want %q", res.PkgBase, tt.base)
+			}
+			if !reflect.DeepEqual(info, tt.repoInfo) {
+				t.Errorf("repoInfo = %v, want %v", info, tt.repoInfo)
+			}
+		})
+	}
+}
+
+func TestEpochTag(t *testing.T) {
+	res, _, err := determineBaseInfo(testContext(t), "python-foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Epoch != 1 {
+		t.Errorf("epoch = %d, want 1", res.Epoch)
+	}
+	if tag := res.tagName(); tag != "1-1.0-1" {
+		t.Errorf("tagName() = %q, want %q", tag, "1-1.0-1")
+	}
+}
+
+func TestDetermineBaseInfoWebFallback(t *testing.T) {
+	tests := []struct {
+		name, pkg, repo string
+	}{
+		{"unknown package", "bar", ""},
+		{"repo not in the sync databases", "linux", "core-testing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := determineBaseInfo(testContext(t), tt.pkg, tt.repo)
+			if !errors.Is(err, http.ErrNotCached) {
+				t.Errorf("determineBaseInfo() error = %v, want a request to the web (failing with http.ErrNotCached)", err)
+			}
+		})
+	}
+}
@@ -1,136 +1,217 @@
 package aur
 
 import (
-	"encoding/xml"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
-	"time"
 
 	"github.com/Necoro/arch-log/pkg/entries"
 	"github.com/Necoro/arch-log/pkg/http"
 	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/provider"
+	"github.com/Necoro/arch-log/pkg/version"
 )
 
-type feed struct {
-	Entries []entry `xml:"entry"`
+func buildUrl(pkg, verb string) string {
+	return fmt.Sprintf("https://aur.archlinux.org/cgit/aur.git/%s/?h=%s", verb, pkg)
 }
 
-type content struct {
-	Type string `xml:"type,attr"`
-	Text string `xml:",chardata"`
-}
+// convert turns the commits into the changes inside the window, tagging each commit that changed the version.
+// Versions are only looked up until the window is done or limit changes have been found (0 for no limit).
+// The last commit is only used as the parent of the one before, unless it is the very first commit.
+func convert(commits []logCommit, version func(i int) (string, error), complete bool, window *provider.Window, limit int) ([]entries.Change, error) {
+	n := len(commits)
+	if !complete && n > 0 {
+		n--
+	}
 
-type entry struct {
-	Title   string    `xml:"title"`
-	Updated string    `xml:"updated"`
-	Author  string    `xml:"author>name"`
-	Content []content `xml:"content"`
-}
+	var changes []entries.Change
+	for i, c := range commits[:n] {
+		if window.Done() || (limit > 0 && len(changes) >= limit) {
+			break
+		}
 
-func (e entry) convertTime() time.Time {
-	if e.Updated == "" {
-		return time.Time{}
-	}
-	if t, err := time.Parse(time.RFC3339, e.Updated); err != nil {
-		log.Warnf("Problem parsing time '%s' -- ignoring: %v.", e.Updated, err)
-		return time.Time{}
-	} else {
-		return t
-	}
-}
+		v, err := version(i)
+		if err != nil {
+			return nil, err
+		}
 
-func (e entry) content() string {
-	for _, c := range e.Content {
-		if c.Type == "text" {
-			return strings.TrimSpace(c.Text)
+		tag := v
+		if i+1 < len(commits) {
+			parent, err := version(i + 1)
+			if err != nil {
+				return nil, err
+			}
+			if v == parent {
+				tag = ""
+			}
 		}
-	}
 
-	return ""
-}
+		change := entries.Change{
+			Id:         c.Id,
+			CommitTime: c.Time,
+			Author:     c.Author,
+			Summary:    c.Subject,
+			Message:    c.Message,
+			Tag:        tag,
+		}
 
-func buildUrl(pkg, verb string) string {
-	return fmt.Sprintf("https://aur.archlinux.org/cgit/aur.git/%s/?h=%s", verb, pkg)
+		if window.Accept(change) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
 }
 
-func fetch(url string) ([]entry, error) {
-	result, err := http.Fetch(url)
-	if err != nil {
-		return nil, err
+func setupFetch(ctx context.Context, pkg, repo string) (string, error) {
+	if repo != "" {
+		return "", errors.New("repo is not supported by AUR")
 	}
-	defer result.Close()
 
-	log.Debugf("Fetching from AUR (%s) successful.", url)
+	basePkg, err := determineBasePkg(ctx, pkg)
+	if err != nil {
+		return "", err
+	}
 
-	var feed feed
-	d := xml.NewDecoder(result)
-	if err := d.Decode(&feed); err != nil {
-		return nil, err
+	if basePkg != pkg {
+		log.From(ctx).Printf("Mapped pkg '%s' to pkgbase '%s'", pkg, basePkg)
 	}
-	return feed.Entries, nil
+	return basePkg, nil
 }
 
-func convert(xmlEntries []entry) []entries.Change {
-	changes := make([]entries.Change, len(xmlEntries))
-	for i, xmlE := range xmlEntries {
-		log.Debugf("Fetched entry %+v", xmlE)
+// GetEntries returns the newest q.Limit changes inside the window given by q, or all of them for a limit of 0.
+// The versions are only looked up until the lower bound of the window is reached.
+func GetEntries(ctx context.Context, q provider.Query) (entries.Log, error) {
+	limit := q.Limit
 
-		changes[i] = entries.Change{
-			CommitTime: xmlE.convertTime(),
-			Author:     xmlE.Author,
-			Summary:    xmlE.Title,
-			Message:    xmlE.content(),
-		}
+	basePkg, err := setupFetch(ctx, q.Pkg, q.Repo)
+	if err != nil {
+		return entries.Log{}, err
 	}
-	return changes
-}
 
-func setupFetch(pkg, repo string) (string, error) {
-	if repo != "" {
-		return "", errors.New("repo is not supported by AUR")
+	fetchLimit := limit
+	if q.To != "" || !q.Until.IsZero() || q.Filter != nil {
+		// the selected changes may be anywhere in the history
+		fetchLimit = 0
 	}
 
-	basePkg, err := determineBasePkg(pkg)
+	// one more than limit: the parent of the last commit is included to determine its tag
+	commits, complete, err := fetchLog(ctx, basePkg, fetchLimit, q.Since)
 	if err != nil {
-		return "", err
+		return entries.Log{}, err
 	}
 
-	if basePkg != pkg {
-		log.Printf("Mapped pkg '%s' to pkgbase '%s'", pkg, basePkg)
+	changes, err := convert(commits, versionLookup(ctx, basePkg, commits), complete, provider.NewWindow(q), limit)
+	if err != nil {
+		return entries.Log{}, err
 	}
-	return basePkg, nil
+
+	return entries.Log{
+		PkgBase: basePkg,
+		Changes: changes,
+	}, nil
 }
 
-func GetEntries(pkg, repo string) ([]entries.Change, error) {
-	basePkg, err := setupFetch(pkg, repo)
+// CommitURL returns the URL of the commit's page in cgit.
+func CommitURL(basePkg, commitId string) string {
+	return buildUrl(basePkg, "commit") + "&id=" + commitId
+}
+
+// GetPatch returns the changes of the given commit as unified diff.
+func GetPatch(ctx context.Context, basePkg, commitId string) (string, error) {
+	url := buildUrl(basePkg, "patch") + "&id=" + commitId
+	body, err := http.Fetch(ctx, url, http.Immutable)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	defer body.Close()
+
+	log.From(ctx).Debugf("Fetching from AUR (%s) successful.", url)
 
-	url := buildUrl(basePkg, "atom")
-	xmlEntries, err := fetch(url)
+	patch, err := io.ReadAll(body)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return convert(xmlEntries), nil
+
+	return stripMail(string(patch)), nil
 }
 
-func GetPkgBuild(pkg, repo string) (io.ReadCloser, error) {
-	basePkg, err := setupFetch(pkg, repo)
+// stripMail removes the mail headers and signature from a patch as generated by 'git format-patch'.
+func stripMail(patch string) string {
+	if idx := strings.Index(patch, "diff --git "); idx > -1 {
+		patch = patch[idx:]
+	}
+
+	if idx := strings.LastIndex(patch, "\n-- \n"); idx > -1 {
+		patch = patch[:idx+1]
+	}
+
+	return patch
+}
+
+// GetPkgBuild returns the PKGBUILD at the given ref, which is either a version or a commit.
+// An empty ref denotes the current version.
+func GetPkgBuild(ctx context.Context, pkg, repo, ref string) (io.ReadCloser, error) {
+	if provider.IsRepoRef(ref) {
+		return nil, fmt.Errorf("ref '%s' names a repo, which is not supported by AUR", ref)
+	}
+
+	basePkg, err := setupFetch(ctx, pkg, repo)
 	if err != nil {
 		return nil, err
 	}
 
 	url := buildUrl(basePkg, "plain/PKGBUILD")
-	body, err := http.Fetch(url)
+	class := http.Content
+	if ref != "" && ref != "HEAD" {
+		commitId, err := resolveRef(ctx, basePkg, ref)
+		if err != nil {
+			return nil, err
+		}
+		url += "&id=" + commitId
+		class = http.Immutable
+	}
+
+	body, err := http.Fetch(ctx, url, class)
 	if err != nil {
-		body.Close()
 		return nil, err
 	}
 
-	log.Debugf("Fetching from AUR (%s) successful.", url)
+	log.From(ctx).Debugf("Fetching from AUR (%s) successful.", url)
 
 	return body, nil
 }
+
+var commitIdRe = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// resolveRef maps the ref to a commit id. If it is a version, this is the newest commit with that version
+// (compared like pacman does, so a version without pkgrel matches the newest of its pkgrels).
+func resolveRef(ctx context.Context, basePkg, ref string) (string, error) {
+	if commitIdRe.MatchString(ref) {
+		return ref, nil
+	}
+
+	for offset := 0; offset >= 0; {
+		var page []logCommit
+		var err error
+		if page, offset, err = fetchLogPage(ctx, basePkg, offset); err != nil {
+			return "", err
+		}
+
+		for _, c := range page {
+			v, err := fetchVersion(ctx, basePkg, c.Id)
+			if err != nil {
+				return "", err
+			}
+			if v != "" && version.Compare(v, ref) == 0 {
+				log.From(ctx).Debugf("Mapped version '%s' to commit %s", ref, c.Id)
+				return c.Id, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("version '%s' not found in history of '%s'", ref, basePkg)
+}
@@ -0,0 +1,143 @@
+package aur
+
+import (
+	"context"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+// cgit's time format used in the title of the age column
+const cgitTimeFormat = "2006-01-02 15:04:05 -0700"
+
+var (
+	rowRe     = regexp.MustCompile(`(?s)<tr[^>]*>(.*?)</tr>`)
+	cellRe    = regexp.MustCompile(`(?s)<td[^>]*>(.*?)</td>`)
+	logMsgRe  = regexp.MustCompile(`(?s)<td[^>]*class='logmsg'[^>]*>(.*?)</td>`)
+	ageRe     = regexp.MustCompile(`<span[^>]*title='([^']+)'`)
+	commitRe  = regexp.MustCompile(`(?s)<a[^>]*href='[^']*/commit/\?[^']*id=([0-9a-f]+)[^']*'[^>]*>(.*?)</a>`)
+	offsetRe  = regexp.MustCompile(`ofs=(\d+)`)
+	htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+)
+
+type logCommit struct {
+	Id      string
+	Time    time.Time
+	Subject string
+	Message string
+	Author  string
+}
+
+func htmlText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(s, "")))
+}
+
+func parseTime(logger log.Logger, s string) time.Time {
+	if t, err := time.Parse(cgitTimeFormat, s); err != nil {
+		logger.Warnf("Problem parsing time '%s' -- ignoring: %v.", s, err)
+		return time.Time{}
+	} else {
+		return t
+	}
+}
+
+// parseLogPage extracts the commits from a cgit log page (with 'showmsg' enabled).
+// It additionally returns the offset of the next page, or -1 if this is the last page.
+func parseLogPage(logger log.Logger, page string, offset int) ([]logCommit, int) {
+	var commits []logCommit
+
+	for _, row := range rowRe.FindAllStringSubmatch(page, -1) {
+		content := row[1]
+
+		if msg := logMsgRe.FindStringSubmatch(content); msg != nil {
+			if len(commits) > 0 {
+				commits[len(commits)-1].Message = htmlText(msg[1])
+			}
+			continue
+		}
+
+		link := commitRe.FindStringSubmatch(content)
+		if link == nil {
+			continue
+		}
+
+		c := logCommit{
+			Id:      link[1],
+			Subject: htmlText(link[2]),
+		}
+
+		if age := ageRe.FindStringSubmatch(content); age != nil {
+			c.Time = parseTime(logger, html.UnescapeString(age[1]))
+		}
+
+		if cells := cellRe.FindAllStringSubmatch(content, -1); len(cells) > 2 {
+			c.Author = htmlText(cells[2][1])
+		}
+
+		commits = append(commits, c)
+	}
+
+	next := -1
+	for _, ofs := range offsetRe.FindAllStringSubmatch(page, -1) {
+		if o, err := strconv.Atoi(ofs[1]); err == nil && o > offset {
+			next = o
+		}
+	}
+
+	return commits, next
+}
+
+func fetchLogPage(ctx context.Context, basePkg string, offset int) ([]logCommit, int, error) {
+	url := buildUrl(basePkg, "log") + "&showmsg=1&ofs=" + strconv.Itoa(offset)
+
+	result, err := http.Fetch(ctx, url, http.History)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer result.Close()
+
+	log.From(ctx).Debugf("Fetching from AUR (%s) successful.", url)
+
+	page, err := io.ReadAll(result)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	commits, next := parseLogPage(log.From(ctx), string(page), offset)
+	return commits, next, nil
+}
+
+// fetchLog fetches the history from newest to oldest commit. It stops as soon as
+// more than limit commits have been found, i.e. if there is a parent of the last
+// needed commit, it is included. A limit of 0 fetches the complete history.
+// Likewise, it stops at the first commit older than since, if given.
+// Returns whether the complete history has been fetched.
+func fetchLog(ctx context.Context, basePkg string, limit int, since time.Time) ([]logCommit, bool, error) {
+	var commits []logCommit
+
+	offset := 0
+	for offset >= 0 && (limit <= 0 || len(commits) <= limit) {
+		var page []logCommit
+		var err error
+		if page, offset, err = fetchLogPage(ctx, basePkg, offset); err != nil {
+			return nil, false, err
+		}
+
+		for _, c := range page {
+			log.From(ctx).Debugf("Fetched commit %+v", c)
+		}
+		commits = append(commits, page...)
+
+		if last := len(commits) - 1; !since.IsZero() && last >= 0 && commits[last].Time.Before(since) {
+			break
+		}
+	}
+
+	return commits, offset < 0, nil
+}
@@ -0,0 +1,97 @@
+package aur
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+func TestParseLogPage(t *testing.T) {
+	page, err := os.ReadFile("testdata/log.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commits, next := parseLogPage(log.Discard, string(page), 50)
+
+	want := []logCommit{
+		{
+			Id:      "3f2c1a9e8b7d6c5f4e3d2c1b0a9f8e7d6c5b4a39",
+			Time:    time.Date(2024, 8, 12, 19, 15, 3, 0, time.UTC),
+			Subject: "Update to 12.3.5",
+			Message: "Update to 12.3.5\n\nFixes <build> with go 1.23 & newer.",
+			Author:  "Jo Doe",
+		},
+		{
+			Id:      "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+			Time:    time.Date(2024, 7, 30, 9, 2, 41, 0, time.UTC),
+			Subject: "Fix <checksums>",
+			Message: "Fix <checksums>",
+			Author:  "Jörg Müller",
+		},
+		{
+			// no logmsg row
+			Id:      "0123456789abcdef0123456789abcdef01234567",
+			Time:    time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC),
+			Subject: "Initial import",
+			Author:  "Jo Doe",
+		},
+	}
+
+	if len(commits) != len(want) {
+		t.Fatalf("parseLogPage() returned %d commits, want %d: %+v", len(commits), len(want), commits)
+	}
+	for i := range want {
+		got := commits[i]
+		if !got.Time.Equal(want[i].Time) {
+			t.Errorf("commit %d: time = %v, want %v", i, got.Time, want[i].Time)
+		}
+		got.Time = want[i].Time
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("commit %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if next != 100 {
+		t.Errorf("parseLogPage() next offset = %d, want 100", next)
+	}
+
+	// the pager only links to pages up to the current one: this is the last page
+	if _, next = parseLogPage(log.Discard, string(page), 100); next != -1 {
+		t.Errorf("parseLogPage() next offset on the last page = %d, want -1", next)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	srcinfo, err := os.ReadFile("testdata/SRCINFO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		srcinfo string
+		want    string
+	}{
+		{"epoch", string(srcinfo), "1:2.1.0-3"},
+		{"no epoch", "pkgbase = foo\n\tpkgver = 1.0\n\tpkgrel = 1\n\npkgname = foo\n", "1.0-1"},
+		{"epoch 0", "pkgbase = foo\n\tpkgver = 1.0\n\tpkgrel = 2\n\tepoch = 0\n", "1.0-2"},
+		{"no pkgver", "pkgbase = foo\n\npkgname = foo\n\tpkgver = 1.0\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVersion(strings.NewReader(tt.srcinfo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("parseVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
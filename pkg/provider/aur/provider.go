@@ -0,0 +1,43 @@
+package aur
+
+import (
+	"context"
+	"io"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+type aurProvider struct{}
+
+func init() {
+	provider.Register(20, aurProvider{})
+}
+
+func (aurProvider) Name() string {
+	return "AUR"
+}
+
+func (aurProvider) Capabilities() provider.Capability {
+	return provider.Tags | provider.Patches
+}
+
+func (aurProvider) ResolveBase(ctx context.Context, pkg, repo string) (string, error) {
+	return setupFetch(ctx, pkg, repo)
+}
+
+func (aurProvider) GetEntries(ctx context.Context, q provider.Query) (entries.Log, error) {
+	return GetEntries(ctx, q)
+}
+
+func (aurProvider) GetPkgBuild(ctx context.Context, pkg, repo, ref string) (io.ReadCloser, error) {
+	return GetPkgBuild(ctx, pkg, repo, ref)
+}
+
+func (aurProvider) GetPatch(ctx context.Context, basePkg, commitId string) (string, error) {
+	return GetPatch(ctx, basePkg, commitId)
+}
+
+func (aurProvider) CommitURL(basePkg, commitId string) string {
+	return CommitURL(basePkg, commitId)
+}
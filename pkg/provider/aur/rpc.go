@@ -1,6 +1,7 @@
 package aur
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -23,14 +24,14 @@ func buildRpcUrl(pkg string) string {
 	return "https://aur.archlinux.org/rpc/?v=5&type=info&arg[]=" + url.QueryEscape(pkg)
 }
 
-func fetchRpcInfo(url string) (result, error) {
-	res, err := http.Fetch(url)
+func fetchRpcInfo(ctx context.Context, url string) (result, error) {
+	res, err := http.Fetch(ctx, url, http.Search)
 	if err != nil {
 		return result{}, err
 	}
 	defer res.Close()
 
-	log.Debugf("Fetching from AUR RPC (%s) successful.", url)
+	log.From(ctx).Debugf("Fetching from AUR RPC (%s) successful.", url)
 
 	var infos infos
 	d := json.NewDecoder(res)
@@ -47,14 +48,14 @@ func fetchRpcInfo(url string) (result, error) {
 	return infos.Results[0], nil
 }
 
-func determineBasePkg(pkg string) (string, error) {
+func determineBasePkg(ctx context.Context, pkg string) (string, error) {
 	url := buildRpcUrl(pkg)
-	result, err := fetchRpcInfo(url)
+	result, err := fetchRpcInfo(ctx, url)
 
 	if err != nil {
 		return "", err
 	}
-	log.Debugf("Pkg Info from AUR RPC: %+v", result)
+	log.From(ctx).Debugf("Pkg Info from AUR RPC: %+v", result)
 
 	return result.PackageBase, nil
 }
@@ -0,0 +1,127 @@
+package aur
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+// parseVersion returns the full version ([epoch:]pkgver-pkgrel) of the pkgbase section of a .SRCINFO.
+func parseVersion(srcinfo io.Reader) (string, error) {
+	var epoch, pkgver, pkgrel string
+
+	scanner := bufio.NewScanner(srcinfo)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if key == "pkgname" {
+			// end of pkgbase section
+			break
+		}
+
+		switch key {
+		case "epoch":
+			epoch = value
+		case "pkgver":
+			pkgver = value
+		case "pkgrel":
+			pkgrel = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if pkgver == "" {
+		return "", nil
+	}
+
+	version := pkgver + "-" + pkgrel
+	if epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+
+	return version, nil
+}
+
+func fetchVersion(ctx context.Context, basePkg, commitId string) (string, error) {
+	url := buildUrl(basePkg, "plain/.SRCINFO") + "&id=" + commitId
+
+	body, err := http.Fetch(ctx, url, http.Immutable)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	log.From(ctx).Debugf("Fetching from AUR (%s) successful.", url)
+
+	return parseVersion(body)
+}
+
+// number of versions fetched at the same time
+const versionBatch = 8
+
+// versionLookup returns a function determining the version of the i-th commit. Each version is fetched
+// only once and only when needed, together with the versions of the following commits, which are
+// fetched concurrently. Versions that cannot be determined are empty; only a cancellation
+// of the context is reported as error.
+func versionLookup(ctx context.Context, basePkg string, commits []logCommit) func(i int) (string, error) {
+	versions := make(map[int]string)
+
+	// fetch determines the versions of the commits [from, to) not known yet.
+	fetch := func(from, to int) error {
+		var missing []int
+		for j := from; j < to; j++ {
+			if _, ok := versions[j]; !ok {
+				missing = append(missing, j)
+			}
+		}
+
+		fetched := make([]string, len(missing))
+		errs := make([]error, len(missing))
+
+		var wg sync.WaitGroup
+		for k, j := range missing {
+			wg.Add(1)
+			go func(k, j int) {
+				defer wg.Done()
+				fetched[k], errs[k] = fetchVersion(ctx, basePkg, commits[j].Id)
+			}(k, j)
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for k, j := range missing {
+			if errs[k] != nil {
+				log.From(ctx).Warnf("Cannot determine version of commit %s -- ignoring: %v", commits[j].Id, errs[k])
+			}
+			versions[j] = fetched[k]
+		}
+		return nil
+	}
+
+	return func(i int) (string, error) {
+		if v, ok := versions[i]; ok {
+			return v, nil
+		}
+
+		if err := fetch(i, min(i+versionBatch, len(commits))); err != nil {
+			return "", err
+		}
+		return versions[i], nil
+	}
+}
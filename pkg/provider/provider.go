@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+)
+
+// Capability describes optional features of a provider.
+type Capability uint
+
+const (
+	// Repos is set for providers that can restrict their lookup to a given repository.
+	Repos Capability = 1 << iota
+	// Tags is set for providers that annotate changes with release tags.
+	Tags
+	// Patches is set for providers that can show the diff of a change.
+	Patches
+)
+
+func (c Capability) Has(o Capability) bool {
+	return c&o == o
+}
+
+// Provider is a source of package history, like the Arch GitLab or the AUR.
+// All lookup methods return entries.ErrNotFound if the package is not known to the provider.
+type Provider interface {
+	// Name is the human-readable name, which is also used to select the provider (case-insensitive).
+	Name() string
+	Capabilities() Capability
+	// ResolveBase maps the package name to its pkgbase.
+	ResolveBase(ctx context.Context, pkg, repo string) (string, error)
+	GetEntries(ctx context.Context, q Query) (entries.Log, error)
+	// GetPkgBuild returns the PKGBUILD at the given ref, which is either empty for the current version,
+	// a version, a commit id, or the name of a repository (see IsRepoRef).
+	GetPkgBuild(ctx context.Context, pkg, repo, ref string) (io.ReadCloser, error)
+	// GetPatch returns the diff of the change with the given id. Only supported with the Patches capability.
+	GetPatch(ctx context.Context, basePkg, id string) (string, error)
+	// CommitURL returns the URL of the web page showing the change with the given id.
+	CommitURL(basePkg, id string) string
+}
+
+// Query describes the changes requested from a provider.
+type Query struct {
+	Pkg  string
+	Repo string
+	// Limit is the number of changes needed. Providers may return more, but should avoid
+	// fetching more than necessary. A limit of 0 requests all changes.
+	Limit int
+	// From and To restrict the changes to the ones after release From up to and including release To.
+	// See Window.
+	From, To string
+	// Since and Until restrict the changes to the ones committed in [Since, Until). Zero times are open bounds.
+	Since, Until time.Time
+	// Filter, if set, selects the changes to return. Changes not selected do not count against the limit.
+	Filter func(entries.Change) bool
+}
+
+// IsRepoRef reports whether the ref names a repository instead of a version or commit.
+// Repositories never contain digits, whereas versions always do (at least in the pkgrel).
+func IsRepoRef(ref string) bool {
+	return ref != "" && ref != "HEAD" && !strings.ContainsAny(ref, "0123456789")
+}
+
+type registration struct {
+	priority int
+	provider Provider
+}
+
+var registry []registration
+
+// Register adds a provider to the registry. Providers are queried in ascending order of priority.
+func Register(priority int, p Provider) {
+	registry = append(registry, registration{priority, p})
+	sort.SliceStable(registry, func(i, j int) bool {
+		return registry[i].priority < registry[j].priority
+	})
+}
+
+// All returns all registered providers in order of precedence.
+func All() []Provider {
+	ps := make([]Provider, len(registry))
+	for i, r := range registry {
+		ps[i] = r.provider
+	}
+	return ps
+}
+
+// Lookup returns the provider with the given name, or nil if there is none.
+func Lookup(name string) Provider {
+	for _, r := range registry {
+		if strings.EqualFold(r.provider.Name(), name) {
+			return r.provider
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/version"
+)
+
+// Window selects the changes between two releases from a stream of changes ordered from newest to oldest.
+// A change belongs to the release of the nearest tag at or before it in the stream, changes newer than
+// all tags are unreleased. The window contains the releases r with From < r <= To, compared like pacman
+// does (see version.Compare), so the bounds do not need to be tagged themselves. Unreleased changes are
+// only included if To is empty. Additionally, the commit time must be in [Since, Until).
+// Empty bounds are open.
+type Window struct {
+	From, To     string
+	Since, Until time.Time
+	// Filter, if set, must accept the change as well
+	Filter  func(entries.Change) bool
+	release string
+	done    bool
+}
+
+func NewWindow(q Query) *Window {
+	return &Window{
+		From:   version.FromTag(q.From),
+		To:     version.FromTag(q.To),
+		Since:  q.Since,
+		Until:  q.Until,
+		Filter: q.Filter,
+	}
+}
+
+// acceptTime reports whether the commit time of the change is inside the window.
+// Changes with unknown time are always accepted.
+func (w *Window) acceptTime(c entries.Change) bool {
+	if c.CommitTime.IsZero() {
+		return true
+	}
+
+	if !w.Since.IsZero() && c.CommitTime.Before(w.Since) {
+		// commits are ordered, all further changes are older
+		w.done = true
+		return false
+	}
+
+	return w.Until.IsZero() || c.CommitTime.Before(w.Until)
+}
+
+// acceptRelease reports whether the current release is inside the window.
+func (w *Window) acceptRelease() bool {
+	if w.release == "" {
+		return w.To == ""
+	}
+
+	if w.From != "" && version.Compare(w.release, w.From) <= 0 {
+		// releases are ordered, all further changes are older
+		w.done = true
+		return false
+	}
+
+	return w.To == "" || version.Compare(w.release, w.To) <= 0
+}
+
+// Accept reports whether the change, which must be older than all changes passed before, is inside the window.
+func (w *Window) Accept(c entries.Change) bool {
+	if w.done {
+		return false
+	}
+
+	if c.Tag != "" {
+		w.release = version.FromTag(c.Tag)
+	}
+
+	if !w.acceptTime(c) || !w.acceptRelease() {
+		return false
+	}
+
+	return w.Filter == nil || w.Filter(c)
+}
+
+// Done reports whether all further changes are outside the window.
+func (w *Window) Done() bool {
+	return w.done
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Necoro/arch-log/pkg/entries"
+)
+
+// stream of changes from newest to oldest, the tags as in the Arch GitLab
+var stream = []entries.Change{
+	{Id: "unreleased"},
+	{Id: "2.0", Tag: "1-2.0-1"},
+	{Id: "2.0-pre"},
+	{Id: "1.5-2", Tag: "1.5-2"},
+	{Id: "1.5", Tag: "1.5-1"},
+	{Id: "1.5-pre"},
+	{Id: "1.0", Tag: "1.0-1"},
+	{Id: "1.0-pre"},
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        Query
+		accepted []string
+		// index of the change after which the window is done, -1 if never
+		doneAt int
+	}{
+		{
+			name:     "open",
+			accepted: []string{"unreleased", "2.0", "2.0-pre", "1.5-2", "1.5", "1.5-pre", "1.0", "1.0-pre"},
+			doneAt:   -1,
+		},
+		{
+			name:     "exclusive lower bound",
+			q:        Query{From: "1.5-1"},
+			accepted: []string{"unreleased", "2.0", "2.0-pre", "1.5-2"},
+			doneAt:   4,
+		},
+		{
+			name: "upper bound without pkgrel",
+			// matches all pkgrels of 1.5
+			q:        Query{To: "1.5"},
+			accepted: []string{"1.5-2", "1.5", "1.5-pre", "1.0", "1.0-pre"},
+			doneAt:   -1,
+		},
+		{
+			name:     "range",
+			q:        Query{From: "1.0-1", To: "1.5-1"},
+			accepted: []string{"1.5", "1.5-pre"},
+			doneAt:   6,
+		},
+		{
+			name: "epoch tag as upper bound",
+			// excludes the unreleased changes
+			q:        Query{To: "1-2.0-1"},
+			accepted: []string{"2.0", "2.0-pre", "1.5-2", "1.5", "1.5-pre", "1.0", "1.0-pre"},
+			doneAt:   -1,
+		},
+		{
+			name: "epoch beats pkgver",
+			// 1:2.0-1 is newer than 2.0-1
+			q:        Query{From: "2.0-1"},
+			accepted: []string{"unreleased", "2.0", "2.0-pre"},
+			doneAt:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWindow(tt.q)
+
+			var accepted []string
+			doneAt := -1
+			for i, c := range stream {
+				if w.Accept(c) {
+					if w.Done() {
+						t.Errorf("change '%s' accepted after the window is done", c.Id)
+					}
+					accepted = append(accepted, c.Id)
+				}
+				if doneAt < 0 && w.Done() {
+					doneAt = i
+				}
+			}
+
+			if !slices.Equal(accepted, tt.accepted) {
+				t.Errorf("accepted %v, want %v", accepted, tt.accepted)
+			}
+			if doneAt != tt.doneAt {
+				t.Errorf("done after change %d, want %d", doneAt, tt.doneAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a package version, split into its parts. Epoch and PkgRel are empty if not given.
+type Version struct {
+	Epoch  string
+	PkgVer string
+	PkgRel string
+}
+
+// Parse splits a version of the form [epoch:]pkgver[-pkgrel].
+func Parse(s string) Version {
+	var v Version
+
+	if epoch, rest, found := strings.Cut(s, ":"); found && isNumber(epoch) {
+		v.Epoch = epoch
+		s = rest
+	}
+
+	if idx := strings.LastIndexByte(s, '-'); idx > -1 {
+		v.PkgRel = s[idx+1:]
+		s = s[:idx]
+	}
+
+	v.PkgVer = s
+	return v
+}
+
+// FromParts creates the version from its parts as stored in the package databases, where an epoch of 0 means none.
+func FromParts(epoch int, pkgver, pkgrel string) Version {
+	v := Version{PkgVer: pkgver, PkgRel: pkgrel}
+	if epoch > 0 {
+		v.Epoch = strconv.Itoa(epoch)
+	}
+	return v
+}
+
+func isNumber(s string) bool {
+	for i := range s {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (v Version) String() string {
+	s := v.PkgVer
+	if v.Epoch != "" {
+		s = v.Epoch + ":" + s
+	}
+	if v.PkgRel != "" {
+		s += "-" + v.PkgRel
+	}
+	return s
+}
+
+// Tag returns the git tag of the version in the Arch GitLab.
+func (v Version) Tag() string {
+	return ToTag(v.String())
+}
+
+// ToTag returns the git tag of the version in the Arch GitLab. As git does not allow ':' in tags,
+// the epoch is separated by '-' instead.
+func ToTag(version string) string {
+	return strings.ReplaceAll(version, ":", "-")
+}
+
+// FromTag reverts ToTag. As neither pkgver nor pkgrel may contain '-', a tag with two of them has an epoch.
+// Other strings are returned unchanged, so that it is safe to call it on versions.
+func FromTag(tag string) string {
+	if !strings.Contains(tag, ":") && strings.Count(tag, "-") == 2 {
+		if epoch, _, _ := strings.Cut(tag, "-"); isNumber(epoch) {
+			return strings.Replace(tag, "-", ":", 1)
+		}
+	}
+	return tag
+}
@@ -0,0 +1,169 @@
+// Package version implements the version comparison of pacman (alpm_pkg_vercmp) and the mapping of versions
+// to the git tags of the Arch GitLab.
+package version
+
+import "strings"
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
+
+// at returns the character at position i, or 0 if i is beyond the end of s.
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+// parseEVR splits a version into epoch, version and release. The release is only present if hasRel is true.
+func parseEVR(evr string) (epoch, version, release string, hasRel bool) {
+	s := 0
+	for s < len(evr) && isDigit(evr[s]) {
+		s++
+	}
+
+	se := strings.LastIndexByte(evr[s:], '-')
+	if se > -1 {
+		se += s
+		release = evr[se+1:]
+		hasRel = true
+	} else {
+		se = len(evr)
+	}
+
+	if at(evr, s) == ':' {
+		epoch = evr[:s]
+		if epoch == "" {
+			epoch = "0"
+		}
+		version = evr[s+1 : se]
+	} else {
+		epoch = "0"
+		version = evr[:se]
+	}
+
+	return
+}
+
+// rpmvercmp compares two version segments, alternating between alphabetic and numeric parts.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := 0, 0
+	ptr1, ptr2 := 0, 0
+
+	for one < len(a) && two < len(b) {
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+
+		// ran to the end of either
+		if one >= len(a) || two >= len(b) {
+			break
+		}
+
+		// separator lengths differ
+		if one-ptr1 != two-ptr2 {
+			if one-ptr1 < two-ptr2 {
+				return -1
+			}
+			return 1
+		}
+
+		ptr1, ptr2 = one, two
+
+		// grab the first completely alpha or completely numeric segment
+		isNum := isDigit(a[ptr1])
+		if isNum {
+			for ptr1 < len(a) && isDigit(a[ptr1]) {
+				ptr1++
+			}
+			for ptr2 < len(b) && isDigit(b[ptr2]) {
+				ptr2++
+			}
+		} else {
+			for ptr1 < len(a) && isAlpha(a[ptr1]) {
+				ptr1++
+			}
+			for ptr2 < len(b) && isAlpha(b[ptr2]) {
+				ptr2++
+			}
+		}
+
+		seg1, seg2 := a[one:ptr1], b[two:ptr2]
+
+		// segments of different types: numeric segments are always newer than alpha segments
+		if seg2 == "" {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+
+			// whichever number has more digits wins
+			if len(seg1) > len(seg2) {
+				return 1
+			}
+			if len(seg2) > len(seg1) {
+				return -1
+			}
+		}
+
+		if rc := strings.Compare(seg1, seg2); rc != 0 {
+			return rc
+		}
+
+		one, two = ptr1, ptr2
+	}
+
+	// all segments compared identically, only the separators differ
+	if one >= len(a) && two >= len(b) {
+		return 0
+	}
+
+	// a remaining alpha string never beats an empty string
+	if (one >= len(a) && !isAlpha(at(b, two))) || isAlpha(at(a, one)) {
+		return -1
+	}
+	return 1
+}
+
+// Compare compares two full versions ([epoch:]pkgver[-pkgrel]) like pacman's vercmp.
+// The result is -1 if a is older than b, 0 if they are equal, and 1 if a is newer than b.
+// The pkgrel is only compared if both versions have one.
+func Compare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	epoch1, ver1, rel1, hasRel1 := parseEVR(a)
+	epoch2, ver2, rel2, hasRel2 := parseEVR(b)
+
+	ret := rpmvercmp(epoch1, epoch2)
+	if ret == 0 {
+		ret = rpmvercmp(ver1, ver2)
+		if ret == 0 && hasRel1 && hasRel2 {
+			ret = rpmvercmp(rel1, rel2)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,149 @@
+package version
+
+import "testing"
+
+// test cases of pacman's test/util/vercmp.sh
+var vercmpTests = []struct {
+	a, b string
+	want int
+}{
+	// all similar length, no pkgrel
+	{"1.5.0", "1.5.0", 0},
+	{"1.5.1", "1.5.0", 1},
+
+	// mixed length
+	{"1.5.1", "1.5", 1},
+
+	// with pkgrel, simple
+	{"1.5.0-1", "1.5.0-1", 0},
+	{"1.5.0-1", "1.5.0-2", -1},
+	{"1.5.0-1", "1.5.1-1", -1},
+	{"1.5.0-2", "1.5.1-1", -1},
+
+	// with pkgrel, mixed lengths
+	{"1.5-1", "1.5.1-1", -1},
+	{"1.5-2", "1.5.1-1", -1},
+	{"1.5-2", "1.5.1-2", -1},
+
+	// mixed pkgrel inclusion
+	{"1.5", "1.5-1", 0},
+	{"1.5-1", "1.5", 0},
+	{"1.1-1", "1.1", 0},
+	{"1.0-1", "1.1", -1},
+	{"1.1-1", "1.0", 1},
+
+	// alphanumeric versions
+	{"1.5b-1", "1.5-1", -1},
+	{"1.5b", "1.5", -1},
+	{"1.5b-1", "1.5", -1},
+	{"1.5b", "1.5.1", -1},
+
+	// from the manpage
+	{"1.0a", "1.0alpha", -1},
+	{"1.0alpha", "1.0b", -1},
+	{"1.0b", "1.0beta", -1},
+	{"1.0beta", "1.0rc", -1},
+	{"1.0rc", "1.0", -1},
+
+	// going crazy? alpha-dotted versions
+	{"1.5.a", "1.5", 1},
+	{"1.5.b", "1.5.a", 1},
+	{"1.5.1", "1.5.b", 1},
+
+	// alpha dots and dashes
+	{"1.5.b-1", "1.5.b", 0},
+	{"1.5-1", "1.5.b", -1},
+
+	// same/similar content, differing separators
+	{"2.0", "2_0", 0},
+	{"2.0_a", "2_0.a", 0},
+	{"2.0a", "2.0.a", -1},
+	{"2___a", "2_a", 1},
+
+	// epoch included version comparisons
+	{"0:1.0", "0:1.0", 0},
+	{"0:1.0", "0:1.1", -1},
+	{"1:1.0", "0:1.0", 1},
+	{"1:1.0", "0:1.1", 1},
+	{"1:1.0", "2:1.1", -1},
+
+	// epoch + sometimes present pkgrel
+	{"1:1.0", "0:1.0-1", 1},
+	{"1:1.0-1", "0:1.1-1", 1},
+
+	// epoch included on one version
+	{"0:1.0", "1.0", 0},
+	{"0:1.0", "1.1", -1},
+	{"0:1.1", "1.0", 1},
+	{"1:1.0", "1.0", 1},
+	{"1:1.0", "1.1", 1},
+	{"1:1.1", "1.1", 1},
+}
+
+func TestCompare(t *testing.T) {
+	for _, tt := range vercmpTests {
+		if got := Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		// as vercmp.sh, check the reverse direction as well
+		if got := Compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Version
+	}{
+		{"1.0", Version{PkgVer: "1.0"}},
+		{"1.0-1", Version{PkgVer: "1.0", PkgRel: "1"}},
+		{"2:1.0-1", Version{Epoch: "2", PkgVer: "1.0", PkgRel: "1"}},
+		{"1:2.0", Version{Epoch: "1", PkgVer: "2.0"}},
+	}
+
+	for _, tt := range tests {
+		got := Parse(tt.in)
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if s := got.String(); s != tt.in {
+			t.Errorf("Parse(%q).String() = %q", tt.in, s)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		version, tag string
+	}{
+		{"1:2.0-1", "1-2.0-1"},
+		{"12:1.2.3-4", "12-1.2.3-4"},
+		{"2.0-1", "2.0-1"},
+		{"6.10.1.arch1-1", "6.10.1.arch1-1"},
+		{"2.0", "2.0"},
+	}
+
+	for _, tt := range tests {
+		if got := ToTag(tt.version); got != tt.tag {
+			t.Errorf("ToTag(%q) = %q, want %q", tt.version, got, tt.tag)
+		}
+		if got := FromTag(tt.tag); got != tt.version {
+			t.Errorf("FromTag(%q) = %q, want %q", tt.tag, got, tt.version)
+		}
+		// versions are passed unchanged
+		if got := FromTag(tt.version); got != tt.version {
+			t.Errorf("FromTag(%q) = %q, want it unchanged", tt.version, got)
+		}
+	}
+}
+
+func TestFromParts(t *testing.T) {
+	if got := FromParts(0, "1.0", "1").Tag(); got != "1.0-1" {
+		t.Errorf("FromParts(0, ...).Tag() = %q, want %q", got, "1.0-1")
+	}
+	if got := FromParts(1, "2.0", "1").Tag(); got != "1-2.0-1" {
+		t.Errorf("FromParts(1, ...).Tag() = %q, want %q", got, "1-2.0-1")
+	}
+}
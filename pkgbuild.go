@@ -1,61 +1,100 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/diff"
 	"github.com/Necoro/arch-log/pkg/log"
-	"github.com/Necoro/arch-log/pkg/provider/arch"
-	"github.com/Necoro/arch-log/pkg/provider/aur"
+	"github.com/Necoro/arch-log/pkg/provider"
 )
 
-func handleResult(what string, pkg string, repo string, f func(string, string) (io.ReadCloser, error)) (bool, error) {
-	log.Debug("Checking ", what)
+func getPkgBuild(ctx context.Context, p provider.Provider, pkg string, repo string) (io.ReadCloser, error) {
+	if options.pkgbuildDiff == "" {
+		return p.GetPkgBuild(ctx, pkg, repo, "")
+	}
+
+	return getPkgBuildDiff(ctx, p, pkg, repo)
+}
 
-	if body, err := f(pkg, repo); err == nil {
-		err := printPkgBuild(body)
-		return false, err
-	} else if errors.Is(err, entries.ErrNotFound) {
-		log.Debug("Not found on ", what)
-		return true, nil
-	} else {
-		return false, fmt.Errorf("error fetching from %s: %w", what, err)
+// parseDiffRange splits a range 'from..to'. Each side may be empty, denoting the current version.
+func parseDiffRange(spec string) (string, string, error) {
+	from, to, found := strings.Cut(spec, "..")
+	if !found {
+		return "", "", fmt.Errorf("invalid range '%s', expected 'from..to'", spec)
 	}
+	return from, to, nil
 }
 
-func fetchPkgBuild(pkg string) (err error) {
-	var notfound bool
+func readPkgBuild(ctx context.Context, p provider.Provider, pkg, repo, ref string) (string, error) {
+	body, err := p.GetPkgBuild(ctx, pkg, repo, ref)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
 
-	if !options.aur {
-		if notfound, err = handleResult("Arch", pkg, options.repo, arch.GetPkgBuild); err != nil {
-			return
-		}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("reading PKGBUILD at '%s': %w", ref, err)
+	}
+	return string(content), nil
+}
+
+func getPkgBuildDiff(ctx context.Context, p provider.Provider, pkg, repo string) (io.ReadCloser, error) {
+	from, to, err := parseDiffRange(options.pkgbuildDiff)
+	if err != nil {
+		return nil, err
+	}
+
+	oldContent, err := readPkgBuild(ctx, p, pkg, repo, from)
+	if err != nil {
+		return nil, err
+	}
+
+	newContent, err := readPkgBuild(ctx, p, pkg, repo, to)
+	if err != nil {
+		return nil, err
 	}
 
-	if options.aur || (notfound && !options.arch) {
-		if notfound, err = handleResult("AUR", pkg, options.repo, aur.GetPkgBuild); err != nil {
-			return
+	name := func(ref string) string {
+		if ref == "" {
+			ref = "HEAD"
 		}
+		return "PKGBUILD@" + ref
 	}
 
-	if notfound {
-		return notFoundError(pkg)
+	d := diff.Unified(name(from), name(to), oldContent, newContent)
+	if d == "" {
+		log.Printf("PKGBUILDs of '%s' and '%s' are identical.", name(from), name(to))
 	}
 
-	return nil
+	return io.NopCloser(strings.NewReader(diff.Colorize(d))), nil
+}
+
+func fetchPkgBuild(ctx context.Context, spec archlog.Spec) error {
+	_, body, err := lookup(ctx, spec, func(ctx context.Context, p provider.Provider) (io.ReadCloser, error) {
+		return getPkgBuild(ctx, p, spec.Name, spec.Repo)
+	})
+	if err != nil {
+		return err
+	}
+
+	return printPkgBuild(ctx, body)
 }
 
-func printPkgBuild(body io.ReadCloser) error {
+func printPkgBuild(ctx context.Context, body io.ReadCloser) error {
 	defer body.Close()
 
 	if pager := os.Getenv("PAGER"); pager != "" {
 		log.Debugf("'PAGER' set as '%s'", pager)
-		return writeToPager(body, pager)
+		return writeToPager(ctx, body, pager)
 	}
 
 	if _, err := io.Copy(os.Stdout, body); err != nil {
@@ -65,13 +104,24 @@ func printPkgBuild(body io.ReadCloser) error {
 	return nil
 }
 
-func writeToPager(body io.ReadCloser, pager string) error {
+// writeToPager pipes the body into the pager. On cancellation of the context, the pager is terminated
+// with SIGTERM instead of being killed, allowing it to restore the terminal.
+func writeToPager(ctx context.Context, body io.ReadCloser, pager string) error {
 	args := strings.Split(pager, " ")
 
-	cmd := exec.Command(args[0], args[1:]...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = time.Second
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// let less(1) show colors
+		cmd.Env = append(os.Environ(), "LESS=R")
+	}
+
 	pipe, err := cmd.StdinPipe()
 	if err != nil {
 		return err
@@ -82,7 +132,9 @@ func writeToPager(body io.ReadCloser, pager string) error {
 		io.Copy(pipe, body)
 	}()
 
-	if err = cmd.Run(); err != nil {
+	if err = cmd.Run(); ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
 		return fmt.Errorf("running PAGER '%s': %v", pager, err)
 	}
 
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Necoro/arch-log/pkg/log"
+)
+
+type jobResult struct {
+	out  bytes.Buffer
+	log  log.Buffer
+	err  error
+	done chan struct{}
+}
+
+// runJobs runs the job for each of the named packages, with at most options.jobs of them at the same time.
+// The output of each job and its messages (see log.From) are buffered and written in order, so that they are never
+// interleaved.
+// Failed jobs do not stop the others; they are reported and summarized in the returned error,
+// which describes the action performed (e.g. "fetching the log").
+func runJobs(ctx context.Context, names []string, action string, job func(ctx context.Context, i int, w io.Writer) error) error {
+	return runJobsTo(ctx, os.Stdout, names, action, job)
+}
+
+// runJobsTo is runJobs writing to out. The output of each job is passed to out with a single call to Write.
+func runJobsTo(ctx context.Context, out io.Writer, names []string, action string, job func(ctx context.Context, i int, w io.Writer) error) error {
+	results := make([]*jobResult, len(names))
+	for i := range results {
+		results[i] = &jobResult{done: make(chan struct{})}
+	}
+
+	go func() {
+		slots := make(chan struct{}, max(1, options.jobs))
+		for i, r := range results {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(i int, r *jobResult) {
+				defer func() { <-slots }()
+				r.err = job(log.WithLogger(ctx, &r.log), i, &r.out)
+				close(r.done)
+			}(i, r)
+		}
+	}()
+
+	var failed []string
+	for i, r := range results {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if _, err := out.Write(r.out.Bytes()); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+		_ = r.log.Flush()
+
+		if r.err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Error(r.err)
+			failed = append(failed, names[i])
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %d of %d packages: %s", action, len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// jsonList combines the JSON documents written to it, one per call to Write, into a single array.
+type jsonList struct {
+	w io.Writer
+	n int
+}
+
+func (l *jsonList) Write(p []byte) (int, error) {
+	doc := bytes.TrimSpace(p)
+	if len(doc) == 0 {
+		return len(p), nil
+	}
+
+	sep := ",\n"
+	if l.n == 0 {
+		sep = "[\n"
+	}
+	l.n++
+
+	if _, err := io.WriteString(l.w, sep); err != nil {
+		return 0, err
+	}
+	if _, err := l.w.Write(doc); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close terminates the array.
+func (l *jsonList) Close() error {
+	end := "\n]\n"
+	if l.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(l.w, end)
+	return err
+}
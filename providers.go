@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/provider"
+	_ "github.com/Necoro/arch-log/pkg/provider/arch"
+	_ "github.com/Necoro/arch-log/pkg/provider/aur"
+)
+
+func lookupProvider(name string) (provider.Provider, error) {
+	if p := provider.Lookup(name); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown provider '%s'", name)
+}
+
+// forcedProviders returns the providers forced by '--provider', '--arch', and '--aur', in order of precedence.
+// If none is forced, nil is returned, i.e. all providers are queried.
+func forcedProviders() ([]provider.Provider, error) {
+	forced := options.providers
+	if options.arch {
+		forced = append(forced, "arch")
+	}
+	if options.aur {
+		forced = append(forced, "aur")
+	}
+
+	if len(forced) == 0 {
+		return nil, nil
+	}
+
+	isForced := make(map[string]bool, len(forced))
+	for _, name := range forced {
+		p, err := lookupProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		isForced[p.Name()] = true
+	}
+
+	// keep order of precedence
+	var selected []provider.Provider
+	for _, p := range provider.All() {
+		if isForced[p.Name()] {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
+
+// notCachedHint adds a hint to errors of packages not cached how to change that.
+func notCachedHint(spec archlog.Spec, err error) error {
+	if errors.Is(err, http.ErrNotCached) {
+		return fmt.Errorf("package '%s' has not been cached, use 'prefetch' while online: %w", spec.Name, err)
+	}
+	return err
+}
+
+// lookup is archlog.Lookup with the client of the run.
+func lookup[T any](ctx context.Context, spec archlog.Spec, get func(context.Context, provider.Provider) (T, error)) (provider.Provider, T, error) {
+	p, value, err := archlog.Lookup(ctx, client, spec, get)
+	return p, value, notCachedHint(spec, err)
+}
+
+// logOptions returns the changes to select, as given by the flags.
+func logOptions() archlog.LogOptions {
+	return archlog.LogOptions{
+		Limit:  options.number,
+		From:   options.from,
+		To:     options.to,
+		Since:  options.sinceTime,
+		Until:  options.untilTime,
+		Filter: options.filter,
+	}
+}
+
+// parsePackage splits a package given as '[repo/]pkg' into repo and name, and determines the providers to query.
+// The repo defaults to the one given by '--repo'.
+func parsePackage(arg string) (archlog.Spec, error) {
+	return client.Spec(arg, options.repo)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Necoro/arch-log/pkg/archlog"
+	"github.com/Necoro/arch-log/pkg/entries"
+	"github.com/Necoro/arch-log/pkg/http"
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/provider"
+)
+
+const (
+	// time granted to running requests when shutting down the server
+	shutdownTimeout = 5 * time.Second
+	// time granted to clients to send the request headers
+	readHeaderTimeout = 10 * time.Second
+)
+
+// errBadRequest marks errors caused by invalid request parameters.
+var errBadRequest = errors.New("bad request")
+
+// statusCode maps the error of a request to the HTTP status returned.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return gohttp.StatusBadRequest
+	case errors.Is(err, entries.ErrNotFound), errors.Is(err, entries.ErrNotInRepo):
+		return gohttp.StatusNotFound
+	case errors.Is(err, http.ErrNotCached):
+		return gohttp.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return gohttp.StatusGatewayTimeout
+	default:
+		return gohttp.StatusBadGateway
+	}
+}
+
+func badRequest(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", errBadRequest, fmt.Sprintf(format, a...))
+}
+
+// serveRequest holds the package and parameters of a request.
+type serveRequest struct {
+	spec archlog.Spec
+	opts archlog.LogOptions
+}
+
+// parseRequest determines the package from the path below the prefix, and the query from the parameters
+// 'repo', 'number', and 'since'. Parameters not given default to the flags the server has been started with.
+func parseRequest(r *gohttp.Request, prefix string) (serveRequest, error) {
+	pkg := strings.TrimPrefix(r.URL.Path, prefix)
+	params := r.URL.Query()
+
+	repo := options.repo
+	if params.Has("repo") {
+		repo = params.Get("repo")
+	}
+
+	spec, err := client.Spec(pkg, repo)
+	if err != nil {
+		return serveRequest{}, badRequest("%v", err)
+	}
+
+	opts := logOptions()
+
+	if since := params.Get("since"); since != "" {
+		if opts.Since, _, err = parseDate(since, time.Now()); err != nil {
+			return serveRequest{}, badRequest("invalid 'since': %v", err)
+		}
+		opts.Limit = 0
+	}
+
+	if number := params.Get("number"); number != "" {
+		if opts.Limit, err = strconv.Atoi(number); err != nil || opts.Limit < 0 {
+			return serveRequest{}, badRequest("invalid 'number' '%s'", number)
+		}
+	}
+
+	return serveRequest{spec, opts}, nil
+}
+
+// handler is the part of an endpoint specific to it. A returned error is reported with the matching status,
+// unless the handler has already started writing the response.
+type handler func(ctx context.Context, w gohttp.ResponseWriter, req serveRequest) error
+
+func endpoint(prefix string, h handler) (string, gohttp.HandlerFunc) {
+	return prefix, func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		if r.Method != gohttp.MethodGet && r.Method != gohttp.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			gohttp.Error(w, "method not allowed", gohttp.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+		if options.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, options.timeout)
+			defer cancel()
+		}
+
+		req, err := parseRequest(r, prefix)
+		if err == nil {
+			err = h(ctx, w, req)
+		}
+
+		if err != nil {
+			if r.Context().Err() != nil {
+				log.Debugf("Request '%s' cancelled by the client.", r.URL)
+				return
+			}
+
+			status := statusCode(err)
+			if status >= 500 {
+				log.Warnf("Request '%s' failed: %v", r.URL, err)
+			} else {
+				log.Debugf("Request '%s' failed with status %d: %v", r.URL, status, err)
+			}
+			gohttp.Error(w, err.Error(), status)
+			return
+		}
+
+		log.Debugf("Served '%s'.", r.URL)
+	}
+}
+
+func serveLog(ctx context.Context, w gohttp.ResponseWriter, req serveRequest) error {
+	res, err := fetchLogResult(ctx, req.spec, req.opts)
+	if err != nil {
+		return err
+	}
+	res.Changes = selectEntries(res.Changes, req.opts.Limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	return formatJSON(w, res)
+}
+
+func serveFeed(ctx context.Context, w gohttp.ResponseWriter, req serveRequest) error {
+	res, err := fetchLogResult(ctx, req.spec, req.opts)
+	if err != nil {
+		return err
+	}
+	res.Changes = selectEntries(res.Changes, req.opts.Limit)
+
+	w.Header().Set("Content-Type", "application/atom+xml")
+	return writeFeed(w, req.spec.String(), []logResult{res})
+}
+
+func servePkgBuild(ctx context.Context, w gohttp.ResponseWriter, req serveRequest) error {
+	_, body, err := lookup(ctx, req.spec, func(ctx context.Context, p provider.Provider) (io.ReadCloser, error) {
+		return p.GetPkgBuild(ctx, req.spec.Name, req.spec.Repo, "")
+	})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func newServeMux() *gohttp.ServeMux {
+	mux := gohttp.NewServeMux()
+	mux.HandleFunc(endpoint("/log/", serveLog))
+	mux.HandleFunc(endpoint("/feed/", serveFeed))
+	mux.HandleFunc(endpoint("/pkgbuild/", servePkgBuild))
+	return mux
+}
+
+// runServe answers queries over HTTP until interrupted. Then it shuts down gracefully.
+// The run's --timeout is applied to each request instead (see command.server).
+func runServe(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: serve [--listen address]")
+	}
+
+	server := &gohttp.Server{
+		Addr:              options.listen,
+		Handler:           newServeMux(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on '%s'.", options.listen)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("serving: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Debug("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/Necoro/arch-log/pkg/diff"
+	"github.com/Necoro/arch-log/pkg/entries"
+)
+
+// built-in templates, selectable by name
+var builtinTemplates = map[string]string{
+	"oneline": `{{ date "short" .CommitTime | color "yellow,bold" }}` +
+		`{{ with .Tag }} {{ surround "(" ")" . | color "green" }}{{ end }}` +
+		` {{ .Summary }}`,
+
+	"medium": `{{ date "iso" .CommitTime | color "yellow,bold" }}` +
+		`{{ with .Tag }} {{ surround "(" ")" . | color "green" }}{{ end }}` +
+		`{{ with .RepoInfo }} {{ surround "[" "]" . | color "yellow" }}{{ end }}
+Author: {{ .Author }}
+
+{{ indent 4 .Summary }}
+
+`,
+
+	"full": `{{ date "iso" .CommitTime | color "yellow,bold" }}{{ with date "relative" .CommitTime }} ({{ . }}){{ end }}` +
+		`{{ with .Tag }} {{ surround "(" ")" . | color "green" }}{{ end }}` +
+		`{{ with .RepoInfo }} {{ surround "[" "]" . | color "yellow" }}{{ end }}
+Package: {{ .PkgBase }} ({{ .Provider }}{{ with .Repo }}/{{ . }}{{ end }})
+Commit:  {{ .Id }}
+Author:  {{ .Author }}
+
+{{ indent 4 .Summary }}
+{{ with .Message }}
+{{ indent 4 . }}
+{{ end }}{{ with .Patch }}
+{{ colordiff . }}{{ end }}
+`,
+}
+
+// templateEntry is the data passed to a template for each change.
+type templateEntry struct {
+	entries.Change
+	PkgBase  string
+	Provider string
+	Repo     string
+	// TagWidth and RepoWidth are the maximum lengths of Tag and RepoInfo, respectively, over all shown changes.
+	TagWidth  int
+	RepoWidth int
+}
+
+var colorAttributes = map[string]color.Attribute{
+	"bold":      color.Bold,
+	"faint":     color.Faint,
+	"italic":    color.Italic,
+	"underline": color.Underline,
+	"black":     color.FgBlack,
+	"red":       color.FgRed,
+	"green":     color.FgGreen,
+	"yellow":    color.FgYellow,
+	"blue":      color.FgBlue,
+	"magenta":   color.FgMagenta,
+	"cyan":      color.FgCyan,
+	"white":     color.FgWhite,
+}
+
+// tmplColor colors the text according to spec, which is a comma-separated list of attributes, e.g. "yellow,bold".
+func tmplColor(spec, text string) (string, error) {
+	var attrs []color.Attribute
+	for _, name := range strings.Split(spec, ",") {
+		attr, ok := colorAttributes[strings.TrimSpace(name)]
+		if !ok {
+			return "", fmt.Errorf("unknown color attribute '%s'", name)
+		}
+		attrs = append(attrs, attr)
+	}
+
+	return color.New(attrs...).Sprint(text), nil
+}
+
+// tmplDate formats the time with the given layout, which is either one of
+// "short", "iso", "rfc3339", "relative" or a Go time layout.
+func tmplDate(layout string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	switch layout {
+	case "short":
+		layout = time.DateOnly
+	case "iso":
+		layout = time.DateTime
+	case "rfc3339":
+		layout = time.RFC3339
+	case "relative":
+		return relativeTime(t)
+	}
+
+	return t.Local().Format(layout)
+}
+
+func relativeTime(t time.Time) string {
+	d := time.Since(t)
+
+	unit := func(n int, name string) string {
+		if n != 1 {
+			name += "s"
+		}
+		return fmt.Sprintf("%d %s ago", n, name)
+	}
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return unit(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return unit(int(d/time.Hour), "hour")
+	case d < 14*24*time.Hour:
+		return unit(int(d/(24*time.Hour)), "day")
+	case d < 60*24*time.Hour:
+		return unit(int(d/(7*24*time.Hour)), "week")
+	case d < 365*24*time.Hour:
+		return unit(int(d/(30*24*time.Hour)), "month")
+	default:
+		return unit(int(d/(365*24*time.Hour)), "year")
+	}
+}
+
+func tmplTruncate(n int, s string) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func tmplPad(n int, s string) string {
+	return fmt.Sprintf("%-*s", n, s)
+}
+
+func tmplLPad(n int, s string) string {
+	return fmt.Sprintf("%*s", n, s)
+}
+
+func tmplIndent(n int, s string) string {
+	prefix := strings.Repeat(" ", n)
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tmplSurround(left, right, s string) string {
+	if s == "" {
+		return ""
+	}
+	return left + s + right
+}
+
+var templateFuncs = template.FuncMap{
+	"color":     tmplColor,
+	"date":      tmplDate,
+	"truncate":  tmplTruncate,
+	"pad":       tmplPad,
+	"lpad":      tmplLPad,
+	"indent":    tmplIndent,
+	"surround":  tmplSurround,
+	"trim":      strings.TrimSpace,
+	"add":       func(a, b int) int { return a + b },
+	"colordiff": diff.Colorize,
+}
+
+// templateFormatter returns a formatter applying the template to each change.
+// The text is either the name of a built-in template or a template itself.
+func templateFormatter(text string) (formatter, error) {
+	name := "custom"
+	if builtin, ok := builtinTemplates[text]; ok {
+		name = text
+		text = builtin
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return func(w io.Writer, res logResult) error {
+		tagWidth := maxTagLength(res.Changes)
+		repoWidth := maxRepoLength(res.Changes)
+
+		var buf bytes.Buffer
+		for _, c := range res.Changes {
+			buf.Reset()
+
+			data := templateEntry{
+				Change:    c,
+				PkgBase:   res.PkgBase,
+				Provider:  res.provider.Name(),
+				Repo:      res.Repo,
+				TagWidth:  tagWidth,
+				RepoWidth: repoWidth,
+			}
+
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return fmt.Errorf("executing template: %w", err)
+			}
+
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+
+			if _, err := buf.WriteTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Necoro/arch-log/pkg/log"
+	"github.com/Necoro/arch-log/pkg/pacman"
+	"github.com/Necoro/arch-log/pkg/provider/arch"
+)
+
+// pacmanDB returns the pacman database given by '--root' and '--dbpath'.
+func pacmanDB() pacman.DB {
+	return pacman.DB{Root: options.root, Path: options.dbPath}
+}
+
+var localPackages = sync.OnceValues(func() ([]pacman.Package, error) {
+	return pacmanDB().Local()
+})
+
+// installedVersion returns the version of the installed package.
+func installedVersion(name string) (string, error) {
+	pkgs, err := localPackages()
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range pkgs {
+		if p.Name == name {
+			return p.Version, nil
+		}
+	}
+
+	return "", fmt.Errorf("package '%s' is not installed", name)
+}
+
+func fetchUpgradeLog(ctx context.Context, w io.Writer, u pacman.Upgrade) error {
+	title := fmt.Sprintf("%s %s -> %s [%s]", u.Installed.Name, u.Installed.Version, u.Available.Version, u.Available.Repo)
+	if err := printHeader(w, title); err != nil {
+		return err
+	}
+
+	// packages of other repos (e.g. custom ones) are looked up by name only, they might be found on the AUR
+	repo := u.Available.Repo
+	if !arch.IsOfficialRepo(repo) {
+		repo = ""
+	}
+
+	spec, err := client.Spec(u.Available.Name, repo)
+	if err != nil {
+		return err
+	}
+
+	opts := logOptions()
+	opts.From = u.Installed.Version
+	opts.To = u.Available.Version
+
+	return fetchLogWith(ctx, w, spec, opts)
+}
+
+// fetchUpgradeLogs shows the changes of all packages with pending upgrades.
+// Split packages are only shown once per pkgbase.
+func fetchUpgradeLogs(ctx context.Context) error {
+	upgrades, err := pacmanDB().Upgrades(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(upgrades) == 0 {
+		log.Print("No pending upgrades.")
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var selected []pacman.Upgrade
+	var names []string
+	for _, u := range upgrades {
+		if seen[u.Available.Base] {
+			log.Debugf("Skipping '%s', pkgbase '%s' already shown", u.Available.Name, u.Available.Base)
+			continue
+		}
+		seen[u.Available.Base] = true
+		selected = append(selected, u)
+		names = append(names, u.Available.Name)
+	}
+
+	return runLogJobs(ctx, names, func(ctx context.Context, i int, w io.Writer) error {
+		return fetchUpgradeLog(ctx, w, selected[i])
+	})
+}